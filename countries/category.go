@@ -0,0 +1,60 @@
+package countries
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+
+	"github.com/dys2p/eco/lang"
+)
+
+// A Category is a product category which is taxed at different rates in the member states.
+type Category string
+
+const (
+	CategoryBooks             Category = "books"
+	CategoryEBooks            Category = "e-books"
+	CategoryFood              Category = "food"
+	CategoryChildrensClothing Category = "childrens-clothing"
+)
+
+var Categories = []Category{CategoryBooks, CategoryEBooks, CategoryFood, CategoryChildrensClothing}
+
+func (cat Category) TranslateName(l lang.Lang) string {
+	switch cat {
+	case CategoryBooks:
+		return l.Tr("Books")
+	case CategoryEBooks:
+		return l.Tr("E-books")
+	case CategoryFood:
+		return l.Tr("Food")
+	case CategoryChildrensClothing:
+		return l.Tr("Children's clothing")
+	default:
+		return string(cat)
+	}
+}
+
+// The mapping reflects the general rule for each category. Some products within a category, e.g. luxury food, may be taxed at a different rate.
+//
+//go:embed vat-categories.json
+var vatCategoriesJSON []byte
+
+// vatCategories maps categories and countries to rate keys. Countries which are missing apply their standard rate.
+var vatCategories = mustParseVATCategories(vatCategoriesJSON)
+
+func mustParseVATCategories(data []byte) map[Category]map[Country]Rate {
+	var categories map[Category]map[Country]Rate
+	if err := json.Unmarshal(data, &categories); err != nil {
+		panic(fmt.Sprintf("parsing embedded vat categories: %v", err))
+	}
+	return categories
+}
+
+// RateFor returns the key of the VAT rate which the country applies to the category. If the country has no special rate for the category, RateStandard is returned.
+func RateFor(country Country, category Category) Rate {
+	if rate, ok := vatCategories[category][country]; ok {
+		return rate
+	}
+	return RateStandard
+}
@@ -1,9 +1,10 @@
-// Package countries contains European countries and their VAT rates from an European Union point of view.
+// Package countries contains European countries and their VAT rates from an European Union point of view, plus a list of all ISO 3166-1 countries with translated names.
 package countries
 
 import (
 	"slices"
 	"sort"
+	"time"
 
 	"github.com/dys2p/eco/lang"
 )
@@ -48,6 +49,7 @@ const (
 	ME Country = "ME"
 )
 
+// All contains the countries which have a translated name in our catalog: the member states of the European Union and selected non-EU countries. See World for all countries.
 var All = []Country{AT, BE, BG, CH, CY, CZ, DE, DK, EE, ES, FI, FR, GB, GR, HR, HU, IE, IT, LT, LU, LV, ME, MT, NL, PL, PT, RO, SE, SI, SK}
 
 var EuropeanUnion = []Country{AT, BE, BG, CY, CZ, DE, DK, EE, ES, FI, FR, GR, HR, HU, IE, IT, LT, LU, LV, MT, NL, PL, PT, RO, SE, SI, SK}
@@ -61,6 +63,7 @@ func Get(cs []Country, id string) (Country, bool) {
 	return "", false
 }
 
+// InEuropeanUnion returns whether country is a member state. Note that some special territories of member states are outside of the EU VAT area, use ResolveVAT for addresses.
 func InEuropeanUnion(country Country) bool {
 	return slices.Contains(EuropeanUnion, country)
 }
@@ -128,180 +131,26 @@ func (c Country) TranslateName(l lang.Lang) string {
 	case SK:
 		return l.Tr("Slovakia")
 	default:
-		return string(c)
+		return c.translateCLDR(l)
 	}
 }
 
-// VAT rates are from: https://europa.eu/youreurope/business/taxation/vat/vat-rules-rates/index_en.htm#shortcut-5.
-// Note that the ISO 3166-1 code for Greece is "GR", but the VAT rate table uses its ISO 639-1 code "EL".
+// VAT returns the VAT rates which apply today. It does not consider special territories, see ResolveVAT.
 func (c Country) VAT() VATRates {
-	switch c {
-	case AT:
-		return map[Rate]float64{
-			RateStandard: 0.20,
-			RateReduced1: 0.10,
-			RateReduced2: 0.13,
-			RateParking:  0.13,
-		}
-	case BE:
-		return map[Rate]float64{
-			RateStandard: 0.21,
-			RateReduced1: 0.06,
-			RateReduced2: 0.12,
-			RateParking:  0.12,
-		}
-	case BG:
-		return map[Rate]float64{
-			RateStandard: 0.20,
-			RateReduced1: 0.09,
-		}
-	case CY:
-		return map[Rate]float64{
-			RateStandard: 0.19,
-			RateReduced1: 0.05,
-			RateReduced2: 0.09,
-		}
-	case CZ:
-		return map[Rate]float64{
-			RateStandard: 0.21,
-			RateReduced1: 0.10,
-			RateReduced2: 0.15,
-		}
-	case DE:
-		return map[Rate]float64{
-			RateStandard: 0.19,
-			RateReduced1: 0.07,
-		}
-	case DK:
-		return map[Rate]float64{
-			RateStandard: 0.25,
-		}
-	case EE:
-		return map[Rate]float64{
-			RateStandard: 0.20,
-			RateReduced1: 0.09,
-		}
-	case ES:
-		return map[Rate]float64{
-			RateStandard:     0.21,
-			RateReduced1:     0.10,
-			RateSuperReduced: 0.04,
-		}
-	case FI:
-		return map[Rate]float64{
-			RateStandard: 0.24,
-			RateReduced1: 0.10,
-			RateReduced2: 0.14,
-		}
-	case FR:
-		return map[Rate]float64{
-			RateStandard:     0.20,
-			RateReduced1:     0.055,
-			RateReduced2:     0.10,
-			RateSuperReduced: 0.021,
-		}
-	case GR:
-		return map[Rate]float64{
-			RateStandard: 0.24,
-			RateReduced1: 0.06,
-			RateReduced2: 0.13,
-		}
-	case HR:
-		return map[Rate]float64{
-			RateStandard: 0.25,
-			RateReduced1: 0.05,
-			RateReduced2: 0.13,
-		}
-	case HU:
-		return map[Rate]float64{
-			RateStandard: 0.27,
-			RateReduced1: 0.05,
-			RateReduced2: 0.18,
-		}
-	case IE:
-		return map[Rate]float64{
-			RateStandard:     0.23,
-			RateReduced1:     0.09,
-			RateReduced2:     0.135,
-			RateSuperReduced: 0.048,
-			RateParking:      0.135,
-		}
-	case IT:
-		return map[Rate]float64{
-			RateStandard:     0.22,
-			RateReduced1:     0.05,
-			RateReduced2:     0.10,
-			RateSuperReduced: 0.04,
-		}
-	case LT:
-		return map[Rate]float64{
-			RateStandard: 0.21,
-			RateReduced1: 0.05,
-			RateReduced2: 0.09,
-		}
-	case LU:
-		return map[Rate]float64{
-			RateStandard:     0.17,
-			RateReduced1:     0.08,
-			RateSuperReduced: 0.03,
-			RateParking:      0.14,
-		}
-	case LV:
-		return map[Rate]float64{
-			RateStandard: 0.21,
-			RateReduced1: 0.12,
-			RateReduced2: 0.05,
-		}
-	case MT:
-		return map[Rate]float64{
-			RateStandard: 0.18,
-			RateReduced1: 0.05,
-			RateReduced2: 0.07,
-		}
-	case NL:
-		return map[Rate]float64{
-			RateStandard: 0.21,
-			RateReduced1: 0.09,
-		}
-	case PL:
-		return map[Rate]float64{
-			RateStandard: 0.23,
-			RateReduced1: 0.05,
-			RateReduced2: 0.08,
-		}
-	case PT:
-		return map[Rate]float64{
-			RateStandard: 0.23,
-			RateReduced1: 0.06,
-			RateReduced2: 0.13,
-			RateParking:  0.13,
-		}
-	case RO:
-		return map[Rate]float64{
-			RateStandard: 0.19,
-			RateReduced1: 0.05,
-			RateReduced2: 0.09,
-		}
-	case SE:
-		return map[Rate]float64{
-			RateStandard: 0.25,
-			RateReduced1: 0.06,
-			RateReduced2: 0.12,
-		}
-	case SI:
-		return map[Rate]float64{
-			RateStandard: 0.22,
-			RateReduced1: 0.05,
-			RateReduced2: 0.095,
-		}
-	case SK:
-		return map[Rate]float64{
-			RateStandard: 0.20,
-			RateReduced1: 0.10,
-		}
-	default:
-		return nil
+	return c.VATAt(time.Now())
+}
+
+// VATAt returns the VAT rates which apply at the given date. It returns nil for countries outside of the European Union.
+func (c Country) VATAt(date time.Time) VATRates {
+	day := date.Format("2006-01-02")
+	var rates VATRates
+	for _, period := range vatHistory[c] {
+		if period.ValidFrom > day {
+			break
+		}
+		rates = period.Rates
 	}
+	return rates
 }
 
 type CountryWithName struct {
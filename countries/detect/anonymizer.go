@@ -0,0 +1,130 @@
+package detect
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Anonymizers are Tor exit nodes and commercial VPN servers. Their GeoIP country says nothing about the user.
+const (
+	AnonymizerTor = "tor"
+	AnonymizerVPN = "vpn"
+)
+
+// Anonymizers contains lists of Tor exit nodes and VPN servers, which are read from local files. Download the files with a cron job, for example from https://check.torproject.org/torbulkexitlist.
+type Anonymizers struct {
+	TorExitList string // path, one IP address per line, or the "ExitAddress" lines of https://check.torproject.org/exit-addresses
+	VPNList     string // path, one CIDR prefix or IP address per line
+
+	lock sync.RWMutex
+	tor  map[netip.Addr]struct{}
+	vpn  []netip.Prefix
+}
+
+// Load reads the files. If a path is empty, the respective list is empty. On error, the lists are not changed.
+func (a *Anonymizers) Load() error {
+	var tor = make(map[netip.Addr]struct{})
+	if a.TorExitList != "" {
+		prefixes, err := readPrefixFile(a.TorExitList)
+		if err != nil {
+			return fmt.Errorf("reading tor exit list: %w", err)
+		}
+		for _, prefix := range prefixes {
+			tor[prefix.Addr()] = struct{}{}
+		}
+	}
+
+	var vpn []netip.Prefix
+	if a.VPNList != "" {
+		var err error
+		vpn, err = readPrefixFile(a.VPNList)
+		if err != nil {
+			return fmt.Errorf("reading vpn list: %w", err)
+		}
+	}
+
+	a.lock.Lock()
+	a.tor = tor
+	a.vpn = vpn
+	a.lock.Unlock()
+	return nil
+}
+
+// RunDaemon starts a loop which loads the files every interval. The function blocks.
+func (a *Anonymizers) RunDaemon(interval time.Duration) {
+	for ; true; <-time.Tick(interval) {
+		if err := a.Load(); err != nil {
+			log.Printf("\033[31m"+"error loading anonymizer lists: %v"+"\033[0m", err)
+		}
+	}
+}
+
+// Lookup returns AnonymizerTor, AnonymizerVPN or an empty string.
+func (a *Anonymizers) Lookup(addr netip.Addr) string {
+	addr = addr.Unmap()
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	if _, ok := a.tor[addr]; ok {
+		return AnonymizerTor
+	}
+	for _, prefix := range a.vpn {
+		if prefix.Contains(addr) {
+			return AnonymizerVPN
+		}
+	}
+	return ""
+}
+
+func readPrefixFile(path string) ([]netip.Prefix, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return readPrefixes(file)
+}
+
+// readPrefixes reads one IP address or CIDR prefix per line. Empty lines and comments starting with # are ignored. In lines like "ExitAddress 192.0.2.1 2024-01-01 12:00:00", the second field is used.
+func readPrefixes(r io.Reader) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		value := fields[0]
+		if len(fields) > 1 {
+			if fields[0] != "ExitAddress" {
+				continue // other lines of the exit-addresses format
+			}
+			value = fields[1]
+		} else if fields[0] == "ExitAddress" {
+			continue
+		}
+
+		if strings.Contains(value, "/") {
+			prefix, err := netip.ParsePrefix(value)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, prefix.Masked())
+		} else {
+			addr, err := netip.ParseAddr(value)
+			if err != nil {
+				return nil, err
+			}
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+		}
+	}
+	return prefixes, scanner.Err()
+}
@@ -1,22 +1,59 @@
 // Package detect detects a customer's country options based on their Accept-Language and IP address.
+//
+// For the place of supply of digital services, use Detector.Evidence, which records each signal. To preselect a country in a form, use Detector.Rank.
+// To determine the country once per user and let them change it, use Middleware.
+//
+// IP address detection requires a GeoIP database. Configure it explicitly, for example:
+//
+//	resolver, err := detect.OpenMMDB("/var/lib/GeoIP/GeoLite2-Country.mmdb")
+//	if err != nil {
+//		return err
+//	}
+//	detect.DefaultDetector.GeoIP = resolver
+//
+// Optionally configure Anonymizers, so the GeoIP country of Tor exit nodes and VPN servers is disregarded:
+//
+//	anonymizers := &detect.Anonymizers{TorExitList: "/var/lib/eco/torbulkexitlist"}
+//	go anonymizers.RunDaemon(time.Hour)
+//	detect.DefaultDetector.Anonymizers = anonymizers
+//
+// If your app runs behind a reverse proxy, configure its address and the header it sets in TrustedProxies, see httputil.ParseTrustedProxies. Else the Forwarded and X-Forwarded-For headers are ignored.
 package detect
 
 import (
 	"net/http"
 
 	"github.com/dys2p/eco/countries"
+	"github.com/dys2p/eco/httputil"
 	"golang.org/x/exp/maps"
 )
 
 type detectorFn func(r *http.Request) ([]countries.Country, error)
 
+// A Detector detects countries. Its zero value uses Accept-Language only.
+type Detector struct {
+	GeoIP          Resolver                // optional
+	TrustedProxies httputil.TrustedProxies // reverse proxies whose header is trusted, see httputil.ClientIP
+	SignalFuncs    []SignalFunc            // custom signals for Evidence
+	Weights        *Weights                // for Rank, default: DefaultWeights
+	Anonymizers    *Anonymizers            // optional, GeoIP countries of Tor exit nodes and VPN servers are not trusted
+}
+
+// DefaultDetector is used by Countries.
+var DefaultDetector = &Detector{}
+
+// Countries calls DefaultDetector.Countries.
+func Countries(r *http.Request) ([]countries.Country, bool, error) {
+	return DefaultDetector.Countries(r)
+}
+
 // Countries returns all possible countries for a given HTTP request, based on the client's Accept-Language header and IP address.
 //
 // The result is a slice of European Union countries and a boolean value which indicates "non-EU".
-func Countries(r *http.Request) ([]countries.Country, bool, error) {
+func (d *Detector) Countries(r *http.Request) ([]countries.Country, bool, error) {
 	var eu = make(map[countries.Country]any)
 	var nonEU = false
-	for _, detector := range []detectorFn{acceptLanguage, ipAddress} {
+	for _, detector := range []detectorFn{acceptLanguage, d.ipAddress} {
 		detectedCountries, err := detector(r)
 		if err != nil {
 			return countries.EuropeanUnion, true, err
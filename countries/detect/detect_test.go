@@ -1,12 +1,19 @@
 package detect
 
 import (
+	"encoding/json"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
 	"slices"
+	"strings"
 	"testing"
 
 	"github.com/dys2p/eco/countries"
+	"github.com/dys2p/eco/httputil"
 )
 
 func TestAcceptLanguage(t *testing.T) {
@@ -33,6 +40,22 @@ func TestAcceptLanguage(t *testing.T) {
 }
 
 func TestIPAddress(t *testing.T) {
+	resolver, err := ReadCSV(strings.NewReader(`# de-fra-ovpn-001.relays.mullvad.net
+185.213.155.0,185.213.155.255,DE
+2a03:1b20:6::,2a03:1b20:6:ffff:ffff:ffff:ffff:ffff,DE
+# gr-ath-ovpn-101.relays.mullvad.net
+149.102.246.0,149.102.246.255,GR
+2a02:6ea0:f501::,2a02:6ea0:f501:ffff:ffff:ffff:ffff:ffff,GR
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	trusted, err := httputil.ParseTrustedProxies(httputil.HeaderXForwardedFor, "127.0.0.1", "::1", "192.168.0.0/16")
+	if err != nil {
+		t.Fatal(err)
+	}
+	detector := &Detector{GeoIP: resolver, TrustedProxies: trusted}
+
 	tests := []struct {
 		remoteAddr   string
 		forwardedFor string
@@ -52,6 +75,10 @@ func TestIPAddress(t *testing.T) {
 		{"192.168.1.1", "149.102.246.28", []countries.Country{countries.GR}},
 		{"192.168.1.1", "2a02:6ea0:f501:4::1f", []countries.Country{countries.GR}},
 
+		// spoofed by untrusted client
+		{"185.213.155.66", "149.102.246.28", []countries.Country{countries.DE}},
+		{"149.102.246.28", "127.0.0.1, 185.213.155.66", []countries.Country{countries.GR}},
+
 		// undefined
 		{"1.1.1.1", "", nil},
 		{"127.0.0.1", "1.1.1.1", nil},
@@ -62,7 +89,7 @@ func TestIPAddress(t *testing.T) {
 		req := httptest.NewRequest(http.MethodGet, "/", nil)
 		req.RemoteAddr = test.remoteAddr
 		req.Header.Set("X-Forwarded-For", test.forwardedFor)
-		got, err := ipAddress(req)
+		got, err := detector.ipAddress(req)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -71,3 +98,283 @@ func TestIPAddress(t *testing.T) {
 		}
 	}
 }
+
+func TestCSVResolver(t *testing.T) {
+	_, err := ReadCSV(strings.NewReader("1.0.0.0,1.0.0.255,AU\n1.0.0.128,1.0.1.255,CN\n"))
+	if err == nil {
+		t.Fatal("overlapping ranges: got no error")
+	}
+
+	resolver, err := ReadCSV(strings.NewReader("1.0.0.0,1.0.0.255,AU\n::,::ffff,XX\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]countries.Country{
+		"0.255.255.255":  "",
+		"1.0.0.0":        "AU",
+		"1.0.0.255":      "AU",
+		"1.0.1.0":        "",
+		"::1":            "XX",
+		"::ffff:1.0.0.1": "AU", // IPv4-mapped
+	}
+	for ip, want := range tests {
+		got, err := resolver.Country(net.ParseIP(ip))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("%s: got %s, want %s", ip, got, want)
+		}
+	}
+}
+
+func TestEvidence(t *testing.T) {
+	resolver, err := ReadCSV(strings.NewReader("185.213.155.0,185.213.155.255,DE\n149.102.246.0,149.102.246.255,GR\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	detector := &Detector{GeoIP: resolver}
+
+	tests := []struct {
+		remoteAddr     string
+		acceptLanguage string
+		signals        []Signal
+		want           countries.Country
+		contradicting  []Source
+	}{
+		{"185.213.155.66:1234", "de", []Signal{BillingAddress(countries.DE)}, countries.DE, nil},
+		{"185.213.155.66:1234", "de", nil, "", nil}, // German is not conclusive
+		{"185.213.155.66:1234", "bg", nil, "", nil}, // contradicting
+		{"149.102.246.28:1234", "bg", []Signal{BillingAddress(countries.BG)}, countries.BG, []Source{SourceIPAddress}},
+		{"149.102.246.28:1234", "en", []Signal{BillingAddress(countries.AT), PaymentProvider("paypal", countries.AT)}, countries.AT, []Source{SourceIPAddress}},
+		{"1.1.1.1:1234", "en", []Signal{BillingAddress(countries.AT), BillingAddress(countries.AT)}, "", nil}, // same source
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = test.remoteAddr
+		req.Header.Set("Accept-Language", test.acceptLanguage)
+		got := detector.Evidence(req, test.signals...)
+		if got.Country != test.want {
+			t.Fatalf("%s %s: got %s, want %s", test.remoteAddr, test.acceptLanguage, got.Country, test.want)
+		}
+		if wantAgreed := test.want != "" && len(test.contradicting) == 0; got.Agreed() != wantAgreed {
+			t.Fatalf("%s %s: got agreed %t, want %t", test.remoteAddr, test.acceptLanguage, got.Agreed(), wantAgreed)
+		}
+		if !slices.Equal(got.Contradicting, test.contradicting) {
+			t.Fatalf("%s %s: got contradicting %s, want %s", test.remoteAddr, test.acceptLanguage, got.Contradicting, test.contradicting)
+		}
+
+		// roundtrip
+		data, err := json.Marshal(got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded Evidence
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if decoded.Country != got.Country || len(decoded.Signals) != len(got.Signals) || decoded.Signals[0].Value != got.Signals[0].Value {
+			t.Fatalf("roundtrip: got %+v, want %+v", decoded, got)
+		}
+	}
+}
+
+func TestRank(t *testing.T) {
+	resolver, err := ReadCSV(strings.NewReader("185.213.155.0,185.213.155.255,DE\n1.1.1.0,1.1.1.255,US\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	detector := &Detector{GeoIP: resolver}
+
+	tests := []struct {
+		host           string
+		remoteAddr     string
+		acceptLanguage string
+		want           []countries.Country // top entries
+	}{
+		{"example.com", "192.0.2.1:1234", "de-AT,de;q=0.9", []countries.Country{countries.AT}},
+		{"example.com", "192.0.2.1:1234", "de-DE,de;q=0.9,fr;q=0.5", []countries.Country{countries.DE, countries.LU, countries.NonEU, countries.AT, countries.BE}},
+		{"example.com", "185.213.155.66:1234", "en-US,en;q=0.5", []countries.Country{countries.DE}},
+		{"example.com", "1.1.1.1:1234", "en-US,en;q=0.5", []countries.Country{countries.NonEU}},
+		{"example.at", "192.0.2.1:1234", "de", []countries.Country{countries.AT}},
+		{"example.co.uk:8080", "192.0.2.1:1234", "", []countries.Country{countries.NonEU}},
+		{"example.com", "192.0.2.1:1234", "it", []countries.Country{countries.IT, countries.SI}},
+		{"example.at", "185.213.155.66:1234", "de;q=high", []countries.Country{countries.DE, countries.AT}}, // malformed Accept-Language
+		{"example.com", "invalid", "de-AT,de;q=0.9", []countries.Country{countries.AT}},                     // invalid remote address
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Host = test.host
+		req.RemoteAddr = test.remoteAddr
+		req.Header.Set("Accept-Language", test.acceptLanguage)
+		got, err := detector.Rank(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(countries.EuropeanUnion)+1 {
+			t.Fatalf("got %d scores", len(got))
+		}
+		for i, want := range test.want {
+			if got[i].Country != want {
+				t.Fatalf("%s %s: got %v, want %s at position %d", test.host, test.acceptLanguage, got[:len(test.want)], want, i)
+			}
+		}
+	}
+}
+
+func TestAnonymizers(t *testing.T) {
+	dir := t.TempDir()
+	torPath := filepath.Join(dir, "tor")
+	vpnPath := filepath.Join(dir, "vpn")
+	if err := os.WriteFile(torPath, []byte("ExitNode 0011BD2485AD45D984EC4159C88FC066E5E3300E\nPublished 2024-01-01 10:00:00\nExitAddress 192.0.2.1 2024-01-01 11:00:00\n2001:db8::1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(vpnPath, []byte("# mullvad\n185.213.155.0/24\n2a03:1b20:6::/48\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	anonymizers := &Anonymizers{TorExitList: torPath, VPNList: vpnPath}
+	if err := anonymizers.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"192.0.2.1":            AnonymizerTor,
+		"192.0.2.2":            "",
+		"2001:db8::1":          AnonymizerTor,
+		"185.213.155.66":       AnonymizerVPN,
+		"2a03:1b20:6:f011::1f": AnonymizerVPN,
+		"149.102.246.28":       "",
+	}
+	for ip, want := range tests {
+		if got := anonymizers.Lookup(netip.MustParseAddr(ip)); got != want {
+			t.Fatalf("%s: got %q, want %q", ip, got, want)
+		}
+	}
+
+	resolver, err := ReadCSV(strings.NewReader("185.213.155.0,185.213.155.255,DE\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	detector := &Detector{GeoIP: resolver, Anonymizers: anonymizers}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "185.213.155.66:1234"
+	req.Header.Set("Accept-Language", "de")
+	if got, err := detector.ipAddress(req); err != nil || got != nil {
+		t.Fatalf("ipAddress: got %v, %v, want nil", got, err)
+	}
+	evidence := detector.Evidence(req, BillingAddress(countries.DE))
+	if evidence.Agreed() || evidence.Signals[0].Unreliable != AnonymizerVPN || !slices.Equal(evidence.Signals[0].Countries, []countries.Country{countries.DE}) {
+		t.Fatalf("evidence: got %+v", evidence)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	m := &Middleware{
+		Detector: &Detector{},
+		Key:      []byte("0123456789abcdef0123456789abcdef"),
+	}
+	var got Result
+	handler := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = FromContext(r.Context())
+	}))
+
+	// detection
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Language", "de-DE")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if got != (Result{Country: countries.DE}) {
+		t.Fatalf("detection: got %+v", got)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("got %d cookies", len(cookies))
+	}
+
+	// no signal, tie or non-EU: undetermined without cookie
+	for _, acceptLanguage := range []string{"", "en", "de", "fr", "en-US,en;q=0.5"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Language", acceptLanguage)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if got != (Result{}) {
+			t.Fatalf("undetermined %q: got %+v", acceptLanguage, got)
+		}
+		if n := len(rec.Result().Cookies()); n != 0 {
+			t.Fatalf("undetermined %q: got %d cookies", acceptLanguage, n)
+		}
+	}
+
+	// detected country is read from the cookie
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Language", "fr-FR")
+	req.AddCookie(cookies[0])
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if got != (Result{Country: countries.DE}) {
+		t.Fatalf("cookie: got %+v", got)
+	}
+
+	// change country
+	form := strings.NewReader("country=AT&return=//evil.example.com")
+	req = httptest.NewRequest(http.MethodPost, "/country", form)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Origin", "https://example.com")
+	rec = httptest.NewRecorder()
+	m.ChangeHandler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/" {
+		t.Fatalf("change: got %d %s", rec.Code, rec.Header().Get("Location"))
+	}
+	cookies = rec.Result().Cookies()
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookies[0])
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if got != (Result{Country: countries.AT, Chosen: true}) {
+		t.Fatalf("chosen: got %+v", got)
+	}
+
+	// tampered cookie is ignored
+	tampered := *cookies[0]
+	tampered.Value = strings.Replace(tampered.Value, "AT", "DE", 1)
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Language", "fr-FR")
+	req.AddCookie(&tampered)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if got != (Result{Country: countries.FR}) {
+		t.Fatalf("tampered: got %+v", got)
+	}
+
+	// unknown country
+	req = httptest.NewRequest(http.MethodPost, "/country", strings.NewReader("country=XX"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Referer", "https://example.com/cart")
+	rec = httptest.NewRecorder()
+	m.ChangeHandler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("unknown country: got %d", rec.Code)
+	}
+
+	// cross-site request
+	for _, origin := range []string{"", "https://evil.example.org", "null"} {
+		req = httptest.NewRequest(http.MethodPost, "/country", strings.NewReader("country=AT"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		rec = httptest.NewRecorder()
+		m.ChangeHandler().ServeHTTP(rec, req)
+		if rec.Code != http.StatusForbidden || len(rec.Result().Cookies()) != 0 {
+			t.Fatalf("origin %q: got %d", origin, rec.Code)
+		}
+	}
+
+	// short key
+	defer func() {
+		if recover() == nil {
+			t.Fatal("short key: got no panic")
+		}
+	}()
+	(&Middleware{Key: []byte("secret")}).Handler(handler)
+}
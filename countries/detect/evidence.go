@@ -0,0 +1,159 @@
+package detect
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/dys2p/eco/countries"
+)
+
+// Source is the origin of a Signal. You can define your own sources.
+type Source string
+
+const (
+	SourceAcceptLanguage  Source = "accept-language"
+	SourceBillingAddress  Source = "billing-address"
+	SourceIPAddress       Source = "ip-address"
+	SourcePaymentProvider Source = "payment-provider"
+)
+
+// A Signal is a piece of evidence for the customer's country.
+type Signal struct {
+	Source     Source              `json:"source"`
+	Value      string              `json:"value,omitempty"`      // raw input, e.g. the IP address, the Accept-Language header or the name of the payment provider
+	Countries  []countries.Country `json:"countries,omitempty"`  // possible countries, may contain countries.NonEU
+	Unreliable string              `json:"unreliable,omitempty"` // reason why the signal is not evidence, e.g. AnonymizerTor or AnonymizerVPN
+	Error      string              `json:"error,omitempty"`
+}
+
+// Country returns the country if the signal is reliable and points to exactly one country, else an empty string.
+func (s Signal) Country() countries.Country {
+	if s.Unreliable == "" && len(s.Countries) == 1 && s.Countries[0] != countries.NonEU {
+		return s.Countries[0]
+	}
+	return ""
+}
+
+// BillingAddress returns a signal for the country of the customer's billing address.
+func BillingAddress(country countries.Country) Signal {
+	return Signal{
+		Source:    SourceBillingAddress,
+		Countries: []countries.Country{country},
+	}
+}
+
+// PaymentProvider returns a signal for the country which a payment provider has reported, e.g. the country of the customer's bank or card issuer.
+func PaymentProvider(provider string, country countries.Country) Signal {
+	return Signal{
+		Source:    SourcePaymentProvider,
+		Value:     provider,
+		Countries: []countries.Country{country},
+	}
+}
+
+// A SignalFunc extracts a custom signal from a request. It returns false if the request contains no such signal.
+type SignalFunc func(r *http.Request) (Signal, bool)
+
+// Evidence is a record of the signals for the customer's country.
+//
+// For digital services, the EU requires two non-contradictory pieces of evidence for the place of supply (Art. 24b and 24f of Council Implementing Regulation (EU) No 282/2011).
+// Evidence can be marshaled to JSON and stored with the order.
+type Evidence struct {
+	Time          time.Time         `json:"time"`
+	Signals       []Signal          `json:"signals"`
+	Country       countries.Country `json:"country,omitempty"`       // country which is supported by at least two signals from different sources
+	Supporting    []Source          `json:"supporting,omitempty"`    // sources of the signals which point to Country
+	Contradicting []Source          `json:"contradicting,omitempty"` // sources of the signals which point to exactly one other country
+}
+
+// Agreed returns whether at least two signals from different sources agree on Country and no signal contradicts it, as the EU requires non-contradictory evidence.
+func (e *Evidence) Agreed() bool {
+	return e.Country != "" && len(e.Contradicting) == 0
+}
+
+// Evaluate determines Country, Supporting and Contradicting from the signals. If several countries are supported by two or more sources, the country with the most sources wins. Ties are broken by the order of the signals.
+func (e *Evidence) Evaluate() {
+	e.Country = ""
+	e.Supporting = nil
+	e.Contradicting = nil
+
+	var order []countries.Country
+	var sources = make(map[countries.Country][]Source)
+	for _, signal := range e.Signals {
+		country := signal.Country()
+		if country == "" {
+			continue
+		}
+		if _, ok := sources[country]; !ok {
+			order = append(order, country)
+		}
+		if !containsSource(sources[country], signal.Source) {
+			sources[country] = append(sources[country], signal.Source)
+		}
+	}
+
+	for _, country := range order {
+		if len(sources[country]) >= 2 && len(sources[country]) > len(e.Supporting) {
+			e.Country = country
+			e.Supporting = sources[country]
+		}
+	}
+	if e.Country == "" {
+		return
+	}
+
+	for _, signal := range e.Signals {
+		if country := signal.Country(); country != "" && country != e.Country && !containsSource(e.Contradicting, signal.Source) {
+			e.Contradicting = append(e.Contradicting, signal.Source)
+		}
+	}
+}
+
+func containsSource(sources []Source, source Source) bool {
+	for _, s := range sources {
+		if s == source {
+			return true
+		}
+	}
+	return false
+}
+
+// Evidence collects the IP address and Accept-Language signals of the request, the signals of the custom SignalFuncs and the given signals, for example BillingAddress and PaymentProvider.
+// Errors are recorded in the signals.
+func (d *Detector) Evidence(r *http.Request, signals ...Signal) *Evidence {
+	var e = &Evidence{
+		Time: time.Now().UTC(),
+	}
+
+	ipSignal := Signal{Source: SourceIPAddress}
+	result, err := d.lookupIP(r)
+	if result.addr.IsValid() {
+		ipSignal.Value = result.addr.String()
+	}
+	if result.country != "" {
+		ipSignal.Countries = []countries.Country{result.country}
+	}
+	ipSignal.Unreliable = result.anonymizer
+	if err != nil {
+		ipSignal.Error = err.Error()
+	}
+	e.Signals = append(e.Signals, ipSignal)
+
+	langSignal := Signal{Source: SourceAcceptLanguage, Value: r.Header.Get("Accept-Language")}
+	langCountries, err := acceptLanguage(r)
+	langSignal.Countries = langCountries
+	if err != nil {
+		langSignal.Error = err.Error()
+	}
+	e.Signals = append(e.Signals, langSignal)
+
+	for _, fn := range d.SignalFuncs {
+		if signal, ok := fn(r); ok {
+			e.Signals = append(e.Signals, signal)
+		}
+	}
+
+	e.Signals = append(e.Signals, signals...)
+	e.Evaluate()
+	return e
+}
@@ -0,0 +1,97 @@
+package detect
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/dys2p/eco/countries"
+)
+
+// CSVResolver looks up IP addresses in a list of ranges. It is useful for tests and for the DB-IP "IP to Country Lite" CSV file.
+type CSVResolver struct {
+	ranges []ipRange // sorted by start, not overlapping
+}
+
+type ipRange struct {
+	start   netip.Addr
+	end     netip.Addr
+	country countries.Country
+}
+
+// LoadCSVFile reads a CSV file, see ReadCSV.
+func LoadCSVFile(path string) (*CSVResolver, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadCSV(file)
+}
+
+// ReadCSV reads records of the form "first IP,last IP,ISO 3166-1 code". IPv4 and IPv6 ranges can be mixed. Empty lines and lines starting with # are ignored.
+func ReadCSV(r io.Reader) (*CSVResolver, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+
+	var ranges []ipRange
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, err := netip.ParseAddr(strings.TrimSpace(record[0]))
+		if err != nil {
+			return nil, err
+		}
+		end, err := netip.ParseAddr(strings.TrimSpace(record[1]))
+		if err != nil {
+			return nil, err
+		}
+		start, end = start.Unmap(), end.Unmap()
+		if start.Is4() != end.Is4() || end.Less(start) {
+			return nil, fmt.Errorf("invalid range: %s - %s", start, end)
+		}
+		ranges = append(ranges, ipRange{start, end, countries.Country(strings.TrimSpace(record[2]))})
+	}
+
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].start.Less(ranges[j].start)
+	})
+	for i := 1; i < len(ranges); i++ {
+		if !ranges[i-1].end.Less(ranges[i].start) {
+			return nil, fmt.Errorf("overlapping ranges: %s - %s and %s - %s", ranges[i-1].start, ranges[i-1].end, ranges[i].start, ranges[i].end)
+		}
+	}
+	return &CSVResolver{ranges: ranges}, nil
+}
+
+func (resolver *CSVResolver) Country(ip net.IP) (countries.Country, error) {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return "", errors.New("invalid ip address")
+	}
+	addr = addr.Unmap()
+	// first range which starts after addr
+	i := sort.Search(len(resolver.ranges), func(i int) bool {
+		return addr.Less(resolver.ranges[i].start)
+	})
+	if i == 0 {
+		return "", nil
+	}
+	if r := resolver.ranges[i-1]; r.start.Is4() == addr.Is4() && !r.end.Less(addr) {
+		return r.country, nil
+	}
+	return "", nil
+}
@@ -0,0 +1,47 @@
+package detect
+
+import (
+	"net"
+
+	"github.com/dys2p/eco/countries"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// MMDBResolver reads a MaxMind DB file, for example GeoLite2-Country.mmdb from MaxMind or dbip-country-lite.mmdb from DB-IP. The file contains both IPv4 and IPv6 addresses.
+type MMDBResolver struct {
+	reader *maxminddb.Reader
+}
+
+// OpenMMDB opens a MaxMind DB file. Close it when you are done.
+func OpenMMDB(path string) (*MMDBResolver, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &MMDBResolver{reader: reader}, nil
+}
+
+func (resolver *MMDBResolver) Close() error {
+	return resolver.reader.Close()
+}
+
+// mmdbRecord is the subset of GeoLite2 and DB-IP country records which we need.
+type mmdbRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+}
+
+func (resolver *MMDBResolver) Country(ip net.IP) (countries.Country, error) {
+	var record mmdbRecord
+	if err := resolver.reader.Lookup(ip, &record); err != nil {
+		return "", err
+	}
+	if record.Country.ISOCode != "" {
+		return countries.Country(record.Country.ISOCode), nil
+	}
+	return countries.Country(record.RegisteredCountry.ISOCode), nil
+}
@@ -0,0 +1,19 @@
+package detect
+
+import (
+	"net"
+
+	"github.com/dys2p/eco/countries"
+)
+
+// A Resolver looks up the country of an IP address. It returns an empty country if the address is not found.
+type Resolver interface {
+	Country(ip net.IP) (countries.Country, error)
+}
+
+// NoopResolver resolves no IP addresses. Use it if you have no GeoIP database.
+type NoopResolver struct{}
+
+func (NoopResolver) Country(ip net.IP) (countries.Country, error) {
+	return "", nil
+}
@@ -4,62 +4,64 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"net/netip"
 	"strings"
 
-	"github.com/abh/geoip"
 	"github.com/dys2p/eco/countries"
+	"github.com/dys2p/eco/httputil"
 )
 
-// geoip requires different instances for IPv4 and IPv6, else Open returns the error "Invalid database type GeoIP Country V6 Edition, expected GeoIP Country Edition"
-var geoIPv4 *geoip.GeoIP
-var geoIPv6 *geoip.GeoIP
-
-func init() {
-	var err error
-	geoIPv4, err = geoip.Open("/usr/share/GeoIP/GeoIP.dat")
+// ipAddress returns zero or one country. The country can be outside of the European Union.
+func (d *Detector) ipAddress(r *http.Request) ([]countries.Country, error) {
+	result, err := d.lookupIP(r)
 	if err != nil {
-		panic("Could not open GeoIP database\n")
+		return nil, err
 	}
-	geoIPv6, err = geoip.Open("/usr/share/GeoIP/GeoIPv6.dat")
-	if err != nil {
-		panic("Could not open GeoIP database\n")
+	if country := result.reliableCountry(); country != "" {
+		return []countries.Country{country}, nil
+	} else {
+		return nil, nil
 	}
 }
 
-// ipAddress returns zero or one country. The country can be outside of the European Union.
-func ipAddress(r *http.Request) ([]countries.Country, error) {
+type ipLookup struct {
+	addr       netip.Addr
+	country    countries.Country
+	anonymizer string // AnonymizerTor, AnonymizerVPN or empty
+}
+
+// reliableCountry returns the country unless the address belongs to an anonymizer.
+func (l ipLookup) reliableCountry() countries.Country {
+	if l.anonymizer != "" {
+		return ""
+	}
+	return l.country
+}
+
+// lookupIP returns the client IP address, its country and whether it is a known Tor exit node or VPN server. The result is empty if the request came through a Tor hidden service or if no GeoIP resolver is configured.
+func (d *Detector) lookupIP(r *http.Request) (ipLookup, error) {
 	// Tor users can be anywhere
 	if strings.HasSuffix(r.Host, ".onion") || strings.Contains(r.Host, ".onion:") {
-		return nil, nil
+		return ipLookup{}, nil
 	}
 
-	// first X-Forwarded-For header value overrides http remote address
-	clientAddr := r.RemoteAddr // RemoteAddr is "IP:port"
-	if forwardedFor := strings.FieldsFunc(r.Header.Get("X-Forwarded-For"), func(r rune) bool { return r == ',' || r == ' ' }); len(forwardedFor) > 0 {
-		clientAddr = forwardedFor[0]
+	if d.GeoIP == nil {
+		return ipLookup{}, nil
 	}
 
-	// parse addresses with and without a port number
-	var ip net.IP
-	if tcpAddr, err := net.ResolveTCPAddr("tcp", clientAddr); err == nil { // ResolveTCPAddr requires port number
-		ip = tcpAddr.IP
-	} else {
-		if ipAddr, err := net.ResolveIPAddr("ip", clientAddr); err == nil { // ResolveIPAddr is without port number
-			ip = ipAddr.IP
-		} else {
-			return nil, fmt.Errorf("resolving tcp address: %w", err)
-		}
+	addr, err := httputil.ClientIP(r, d.TrustedProxies)
+	if err != nil {
+		return ipLookup{}, err
 	}
 
-	var country string
-	if ipv4 := ip.To4(); ipv4 != nil {
-		country, _ = geoIPv4.GetCountry(ipv4.String())
-	} else {
-		country, _ = geoIPv6.GetCountry_v6(ip.String()) // "be sure to load a database with IPv6 data to get any results"
+	var result = ipLookup{addr: addr}
+	if d.Anonymizers != nil {
+		result.anonymizer = d.Anonymizers.Lookup(addr)
 	}
-	if country != "" {
-		return []countries.Country{countries.Country(country)}, nil
-	} else {
-		return nil, nil
+
+	result.country, err = d.GeoIP.Country(net.IP(addr.AsSlice()))
+	if err != nil {
+		return result, fmt.Errorf("geoip lookup: %w", err)
 	}
+	return result, nil
 }
@@ -0,0 +1,187 @@
+package detect
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dys2p/eco/countries"
+	"github.com/dys2p/eco/httputil"
+)
+
+// Result is the country of a request, as determined by Middleware.
+type Result struct {
+	Country countries.Country // member state of the European Union or countries.NonEU, empty if undetermined
+	Chosen  bool              // whether the user has chosen the country explicitly
+}
+
+type contextKey struct{}
+
+// FromContext returns the result which Middleware has stored in the context.
+func FromContext(ctx context.Context) (Result, bool) {
+	result, ok := ctx.Value(contextKey{}).(Result)
+	return result, ok
+}
+
+// Middleware determines the country once and stores it in a signed cookie. Then it puts the result into the request context, see FromContext.
+//
+// A country which the user has chosen with ChangeHandler takes priority over detection.
+// A member state is detected only if it has the highest score in Detector.Rank on its own. Else the result is undetermined (empty Country) and no cookie is written, so you should ask the user like if Countries returns all countries.
+// countries.NonEU is never detected, because selling without VAT requires evidence. Users outside the European Union can choose it with ChangeHandler.
+type Middleware struct {
+	Detector   *Detector     // default: DefaultDetector
+	Key        []byte        // secret key for signing the cookie, at least 32 bytes, else Handler and ChangeHandler panic; if empty, no cookie is used, so detection runs on every request and ChangeHandler has no effect
+	CookieName string        // default: "country"
+	MaxAge     time.Duration // default: one year
+}
+
+func (m *Middleware) detector() *Detector {
+	if m.Detector != nil {
+		return m.Detector
+	}
+	return DefaultDetector
+}
+
+func (m *Middleware) cookieName() string {
+	if m.CookieName != "" {
+		return m.CookieName
+	}
+	return "country"
+}
+
+// checkKey panics if m.Key is too short.
+func (m *Middleware) checkKey() {
+	if len(m.Key) > 0 && len(m.Key) < 32 {
+		panic("detect: Middleware.Key must be empty or at least 32 bytes long")
+	}
+}
+
+// Handler returns a handler which puts the result into the request context and calls next.
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	m.checkKey()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result, ok := m.readCookie(r)
+		if !ok {
+			// if undetermined, the result is not stored, so we try again with the next request
+			if country := m.detect(r); country != "" {
+				result = Result{Country: country}
+				m.writeCookie(w, r, result)
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, result)))
+	})
+}
+
+// detect returns the member state with the highest score, or an empty string if there is none or if several countries share the highest score.
+func (m *Middleware) detect(r *http.Request) countries.Country {
+	scores, err := m.detector().Rank(r)
+	if err != nil || scores[0].Score <= 0 || scores[1].Score == scores[0].Score || scores[0].Country == countries.NonEU {
+		return ""
+	}
+	return scores[0].Country
+}
+
+// ChangeHandler returns a handler for the "change country" form. It expects a POST request with the form values "country" (ISO 3166-1 code or "non-EU") and "return" (local path, optional, default "/").
+// Non-EU countries are stored as countries.NonEU.
+// In order to prevent cross-site request forgery, the Origin header (or if it is missing, the Referer header) must match the requested host.
+func (m *Middleware) ChangeHandler() http.Handler {
+	m.checkKey()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !sameOrigin(r) {
+			http.Error(w, "cross-origin request", http.StatusForbidden)
+			return
+		}
+		country := countries.Country(r.PostFormValue("country"))
+		if country != countries.NonEU && !slices.Contains(countries.World, country) {
+			http.Error(w, "unknown country", http.StatusBadRequest)
+			return
+		}
+		if !countries.InEuropeanUnion(country) {
+			country = countries.NonEU
+		}
+		m.writeCookie(w, r, Result{Country: country, Chosen: true})
+
+		returnPath := r.PostFormValue("return")
+		if !strings.HasPrefix(returnPath, "/") || strings.HasPrefix(returnPath, "//") || strings.HasPrefix(returnPath, "/\\") {
+			returnPath = "/" // prevent open redirect
+		}
+		http.Redirect(w, r, returnPath, http.StatusSeeOther)
+	})
+}
+
+// sameOrigin returns whether the Origin header of r, or if it is missing, the Referer header, has the host of r.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		origin = r.Header.Get("Referer")
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host != "" && u.Host == r.Host
+}
+
+// cookie value: country "." ("c" for chosen, "d" for detected) "." signature
+func (m *Middleware) sign(payload string) string {
+	mac := hmac.New(sha256.New, m.Key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (m *Middleware) readCookie(r *http.Request) (Result, bool) {
+	if len(m.Key) == 0 {
+		return Result{}, false
+	}
+	cookie, err := r.Cookie(m.cookieName())
+	if err != nil {
+		return Result{}, false
+	}
+	lastDot := strings.LastIndex(cookie.Value, ".")
+	if lastDot < 0 {
+		return Result{}, false
+	}
+	payload, signature := cookie.Value[:lastDot], cookie.Value[lastDot+1:]
+	if !hmac.Equal([]byte(signature), []byte(m.sign(payload))) {
+		return Result{}, false
+	}
+	country, flag, ok := strings.Cut(payload, ".")
+	if !ok || (flag != "c" && flag != "d") {
+		return Result{}, false
+	}
+	if c := countries.Country(country); c == countries.NonEU || countries.InEuropeanUnion(c) {
+		return Result{Country: c, Chosen: flag == "c"}, true
+	}
+	return Result{}, false
+}
+
+func (m *Middleware) writeCookie(w http.ResponseWriter, r *http.Request, result Result) {
+	if len(m.Key) == 0 {
+		return
+	}
+	flag := "d"
+	if result.Chosen {
+		flag = "c"
+	}
+	payload := string(result.Country) + "." + flag
+	maxAge := m.MaxAge
+	if maxAge == 0 {
+		maxAge = 365 * 24 * time.Hour
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     m.cookieName(),
+		Value:    payload + "." + m.sign(payload),
+		Path:     "/",
+		MaxAge:   int(maxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(httputil.Origin(r), "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
@@ -0,0 +1,134 @@
+package detect
+
+import (
+	"net"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/dys2p/eco/countries"
+	"golang.org/x/text/language"
+)
+
+// Weights configures Rank.
+type Weights struct {
+	AcceptLanguage float64 // multiplied by the q-value of each accepted language
+	Secondary      float64 // factor for countries where the language is official, but not the main language, e.g. German in Italy
+	Region         float64 // multiplied by the q-value of an accepted language with an explicit region, e.g. "de-AT"
+	GeoIP          float64
+	TLD            float64 // top-level domain of the requested host, e.g. ".at"
+}
+
+var DefaultWeights = Weights{
+	AcceptLanguage: 1,
+	Secondary:      0.3,
+	Region:         1,
+	GeoIP:          2,
+	TLD:            0.5,
+}
+
+// secondaryLanguages contains official languages which are not the main language of a country.
+var secondaryLanguages = map[language.Tag][]countries.Country{
+	language.Croatian:  {countries.AT},
+	language.Finnish:   {countries.SE},
+	language.French:    {countries.IT},
+	language.German:    {countries.BE, countries.IT},
+	language.Hungarian: {countries.AT, countries.RO, countries.SI},
+	language.Italian:   {countries.SI},
+	language.Slovenian: {countries.AT, countries.IT},
+	language.Swedish:   {countries.FI},
+	language.Turkish:   {countries.CY},
+}
+
+// tldCountries contains country code top-level domains which differ from the ISO 3166-1 code.
+var tldCountries = map[string]countries.Country{
+	"uk": countries.GB,
+}
+
+// A Score is the result of Rank.
+type Score struct {
+	Country countries.Country
+	Score   float64
+}
+
+// Rank calls DefaultDetector.Rank.
+func Rank(r *http.Request) ([]Score, error) {
+	return DefaultDetector.Rank(r)
+}
+
+// Rank scores the member states of the European Union and countries.NonEU for a given HTTP request, so you can preselect the most likely country and order the rest.
+// Non-EU countries are merged into countries.NonEU. The result is sorted by descending score and contains all candidates, including those with a score of zero.
+// If d.Weights is nil, DefaultWeights are used. A malformed Accept-Language header and errors of the IP address lookup are ignored, so the other signals are still used.
+func (d *Detector) Rank(r *http.Request) ([]Score, error) {
+	weights := DefaultWeights
+	if d.Weights != nil {
+		weights = *d.Weights
+	}
+
+	var scores = make(map[countries.Country]float64)
+	add := func(country countries.Country, score float64) {
+		if !countries.InEuropeanUnion(country) {
+			country = countries.NonEU
+		}
+		scores[country] += score
+	}
+
+	// Accept-Language, a malformed header is ignored
+	tags, qs, _ := language.ParseAcceptLanguage(r.Header.Get("Accept-Language"))
+	for i, tag := range tags {
+		_, index, confidence := matcher.Match(tag)
+		if confidence == language.No {
+			continue
+		}
+		q := float64(qs[i])
+		lc := langCountries[index]
+		for _, country := range lc.countries {
+			if slices.Contains(secondaryLanguages[lc.tag], country) {
+				add(country, q*weights.AcceptLanguage*weights.Secondary)
+			} else {
+				add(country, q*weights.AcceptLanguage)
+			}
+		}
+		if region, confidence := tag.Region(); confidence == language.Exact {
+			add(countries.Country(region.String()), q*weights.Region)
+		}
+	}
+
+	// GeoIP, an error is treated like a missing signal
+	if ip, err := d.lookupIP(r); err == nil {
+		if country := ip.reliableCountry(); country != "" {
+			add(country, weights.GeoIP)
+		}
+	}
+
+	// TLD
+	if country := tldCountry(r.Host); country != "" {
+		add(country, weights.TLD)
+	}
+
+	var result = make([]Score, 0, len(countries.EuropeanUnion)+1)
+	for _, country := range append(slices.Clone(countries.EuropeanUnion), countries.NonEU) {
+		result = append(result, Score{country, scores[country]})
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Score > result[j].Score
+	})
+	return result, nil
+}
+
+// tldCountry returns the country of the top-level domain of host, or an empty string.
+func tldCountry(host string) countries.Country {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(host, ".")
+	tld := strings.ToLower(host[strings.LastIndex(host, ".")+1:])
+	if country, ok := tldCountries[tld]; ok {
+		return country
+	}
+	if country := countries.Country(strings.ToUpper(tld)); len(tld) == 2 && slices.Contains(countries.World, country) {
+		return country
+	}
+	return ""
+}
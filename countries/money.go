@@ -0,0 +1,186 @@
+package countries
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Money is an amount in cents. Calculations with Money use integer arithmetic and round exactly once.
+type Money int64
+
+// String returns the amount with two decimal places, e.g. "-12.05".
+func (m Money) String() string {
+	sign := ""
+	if m < 0 {
+		sign = "-"
+		m = -m
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, m/100, m%100)
+}
+
+// Rounding is a rounding mode for amounts which fall between two cents.
+type Rounding int
+
+const (
+	RoundHalfUp   Rounding = iota // commercial rounding: halves are rounded away from zero
+	RoundHalfEven                 // banker's rounding: halves are rounded to the even cent
+)
+
+// divRound returns n/d rounded to an integer according to the rounding mode. The divisor d must be positive.
+func divRound(n, d int64, rounding Rounding) int64 {
+	q, r := n/d, n%d
+	if r == 0 {
+		return q
+	}
+	sign := int64(1)
+	if n < 0 {
+		sign = -1
+		r = -r
+	}
+	switch {
+	case 2*r > d:
+		q += sign
+	case 2*r == d:
+		if rounding == RoundHalfUp || q%2 != 0 {
+			q += sign
+		}
+	}
+	return q
+}
+
+// BasisPoints returns the VAT rate with the given key in basis points (hundredths of a percent), e.g. 1350 for 13.5 %.
+// Like Rate, it returns the maximum rate if the key is not found.
+func (vr VATRates) BasisPoints(rateKey Rate) (int64, bool) {
+	rate, ok := vr.Rate(rateKey)
+	return int64(math.Round(rate * 10000.0)), ok
+}
+
+// GrossMoney returns the gross of the given net amount using the given VAT rate key. The boolean return value indicates if the rate has been found. If it is not found, the maximum rate is used.
+func (vr VATRates) GrossMoney(net Money, rateKey Rate, rounding Rounding) (Money, bool) {
+	bp, ok := vr.BasisPoints(rateKey)
+	return Money(divRound(int64(net)*(10000+bp), 10000, rounding)), ok
+}
+
+// NetMoney returns the net of the given gross amount using the given VAT rate key. The boolean return value indicates if the rate has been found. If it is not found, the maximum rate is used.
+func (vr VATRates) NetMoney(gross Money, rateKey Rate, rounding Rounding) (Money, bool) {
+	bp, ok := vr.BasisPoints(rateKey)
+	return Money(divRound(int64(gross)*10000, 10000+bp, rounding)), ok
+}
+
+// ConvertMoney converts a gross amount from the source country and rate to the destination country and rate, using the VAT rates which apply at the given date.
+// Unlike chaining NetMoney and GrossMoney, it rounds only once.
+func ConvertMoney(value Money, src Country, srcRate Rate, dst Country, dstRate Rate, date time.Time, rounding Rounding) Money {
+	if src == dst && srcRate == dstRate {
+		return value
+	}
+	srcBP, _ := src.VATAt(date).BasisPoints(srcRate)
+	dstBP, _ := dst.VATAt(date).BasisPoints(dstRate)
+	return Money(divRound(int64(value)*(10000+dstBP), 10000+srcBP, rounding))
+}
+
+// VATFromNet returns the VAT of a net amount at the given rate in basis points.
+func VATFromNet(net Money, bp int64, rounding Rounding) Money {
+	return Money(divRound(int64(net)*bp, 10000, rounding))
+}
+
+// VATFromGross returns the VAT which is contained in a gross amount at the given rate in basis points.
+func VATFromGross(gross Money, bp int64, rounding Rounding) Money {
+	return Money(divRound(int64(gross)*bp, 10000+bp, rounding))
+}
+
+// Strategy determines whether VAT is rounded per invoice line or once per VAT rate.
+type Strategy int
+
+const (
+	RoundTotal Strategy = iota // sum up the lines of each VAT rate, then calculate and round the VAT
+	RoundLine                  // calculate and round the VAT of each line, then sum it up
+)
+
+type InvoiceLine struct {
+	Rate   Rate
+	Amount Money // gross or net, see Invoice.Gross
+}
+
+type Invoice struct {
+	Rates    VATRates
+	Lines    []InvoiceLine
+	Gross    bool // whether line amounts include VAT (usual for consumers) or not (usual for businesses)
+	Rounding Rounding
+	Strategy Strategy
+}
+
+// RateTotal is a line of the VAT breakdown of an invoice. Net plus VAT always equals Gross.
+type RateTotal struct {
+	BasisPoints int64
+	Net         Money
+	VAT         Money
+	Gross       Money
+}
+
+func (total *RateTotal) add(other RateTotal) {
+	total.Net += other.Net
+	total.VAT += other.VAT
+	total.Gross += other.Gross
+}
+
+// split calculates net, VAT and gross of amount.
+func split(amount Money, bp int64, gross bool, rounding Rounding) RateTotal {
+	var result = RateTotal{BasisPoints: bp}
+	if gross {
+		result.Gross = amount
+		result.VAT = VATFromGross(amount, bp, rounding)
+		result.Net = amount - result.VAT
+	} else {
+		result.Net = amount
+		result.VAT = VATFromNet(amount, bp, rounding)
+		result.Gross = amount + result.VAT
+	}
+	return result
+}
+
+// Breakdown returns the net, VAT and gross amounts per VAT rate, ordered by descending rate. Rate keys with the same rate, like a reduced rate and a parking rate, are combined.
+// The boolean return value indicates if all rates have been found. If a rate is not found, the maximum rate is used.
+func (inv Invoice) Breakdown() ([]RateTotal, bool) {
+	var allFound = true
+	var sums = make(map[int64]Money) // RoundTotal: amount per basis points
+	var totals = make(map[int64]*RateTotal)
+	for _, line := range inv.Lines {
+		bp, ok := inv.Rates.BasisPoints(line.Rate)
+		if !ok {
+			allFound = false
+		}
+		if totals[bp] == nil {
+			totals[bp] = &RateTotal{BasisPoints: bp}
+		}
+		switch inv.Strategy {
+		case RoundLine:
+			totals[bp].add(split(line.Amount, bp, inv.Gross, inv.Rounding))
+		default:
+			sums[bp] += line.Amount
+		}
+	}
+	for bp, sum := range sums {
+		*totals[bp] = split(sum, bp, inv.Gross, inv.Rounding)
+	}
+
+	var result = make([]RateTotal, 0, len(totals))
+	for _, total := range totals {
+		result = append(result, *total)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].BasisPoints > result[j].BasisPoints
+	})
+	return result, allFound
+}
+
+// Total returns the sum of the breakdown. Its BasisPoints field is zero.
+func (inv Invoice) Total() (RateTotal, bool) {
+	breakdown, ok := inv.Breakdown()
+	var total RateTotal
+	for _, item := range breakdown {
+		total.add(item)
+	}
+	return total, ok
+}
@@ -0,0 +1,109 @@
+package countries
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDivRound(t *testing.T) {
+	tests := []struct {
+		n, d     int64
+		rounding Rounding
+		want     int64
+	}{
+		{5, 2, RoundHalfUp, 3},
+		{5, 2, RoundHalfEven, 2},
+		{7, 2, RoundHalfEven, 4},
+		{-5, 2, RoundHalfUp, -3},
+		{-5, 2, RoundHalfEven, -2},
+		{-7, 2, RoundHalfEven, -4},
+		{10, 3, RoundHalfUp, 3},
+		{11, 3, RoundHalfEven, 4},
+		{-11, 3, RoundHalfUp, -4},
+		{6, 3, RoundHalfEven, 2},
+	}
+	for _, test := range tests {
+		if got := divRound(test.n, test.d, test.rounding); got != test.want {
+			t.Fatalf("%d/%d: got %d, want %d", test.n, test.d, got, test.want)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := map[Money]string{
+		0:     "0.00",
+		5:     "0.05",
+		-5:    "-0.05",
+		1234:  "12.34",
+		-1200: "-12.00",
+	}
+	for m, want := range tests {
+		if got := m.String(); got != want {
+			t.Fatalf("got %s, want %s", got, want)
+		}
+	}
+}
+
+func TestGrossNetMoney(t *testing.T) {
+	rates := VATRates{RateStandard: 0.19, RateReduced1: 0.07}
+	tests := []struct {
+		net   Money
+		rate  Rate
+		gross Money
+	}{
+		{100, RateStandard, 119},
+		{100, RateReduced1, 107},
+		{50, RateReduced1, 54}, // 53.5 rounded half up
+	}
+	for _, test := range tests {
+		if got, _ := rates.GrossMoney(test.net, test.rate, RoundHalfUp); got != test.gross {
+			t.Fatalf("gross: got %d, want %d", got, test.gross)
+		}
+	}
+	if got, _ := rates.GrossMoney(50, RateReduced1, RoundHalfEven); got != 54 {
+		t.Fatalf("gross: got %d, want 54", got)
+	}
+	if got, _ := rates.NetMoney(119, RateStandard, RoundHalfUp); got != 100 {
+		t.Fatalf("net: got %d, want 100", got)
+	}
+	if got, ok := (VATRates{RateStandard: 0.135}).BasisPoints(RateStandard); got != 1350 || !ok {
+		t.Fatalf("basis points: got %d, want 1350", got)
+	}
+}
+
+func TestInvoiceBreakdown(t *testing.T) {
+	// 1.99 gross at 19 % contains 0.3177 VAT
+	var lines []InvoiceLine
+	for i := 0; i < 10; i++ {
+		lines = append(lines, InvoiceLine{RateStandard, 199})
+	}
+	lines = append(lines, InvoiceLine{RateReduced1, 1070})
+
+	tests := []struct {
+		strategy Strategy
+		want     []RateTotal
+	}{
+		{RoundTotal, []RateTotal{{1900, 1672, 318, 1990}, {700, 1000, 70, 1070}}},
+		{RoundLine, []RateTotal{{1900, 1670, 320, 1990}, {700, 1000, 70, 1070}}},
+	}
+	for _, test := range tests {
+		inv := Invoice{
+			Rates:    VATRates{RateStandard: 0.19, RateReduced1: 0.07},
+			Lines:    lines,
+			Gross:    true,
+			Rounding: RoundHalfUp,
+			Strategy: test.strategy,
+		}
+		got, ok := inv.Breakdown()
+		if !ok {
+			t.Fatal("rate not found")
+		}
+		if !slices.Equal(got, test.want) {
+			t.Fatalf("got %v, want %v", got, test.want)
+		}
+		total, _ := inv.Total()
+		if total.Gross != 3060 || total.Net+total.VAT != total.Gross {
+			t.Fatalf("total: got %v", total)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package countries
+
+import (
+	"strings"
+	"time"
+
+	"github.com/dys2p/eco/lang"
+)
+
+// A Territory is a special territory of a European Union member state which is outside of the EU VAT area, and possibly outside of the EU customs territory.
+// Supplies of goods to a territory outside of the EU VAT area are exports.
+type Territory struct {
+	ID            string
+	Country       Country  // member state which the territory belongs to
+	Code          Country  // own ISO 3166-1 code, if the territory has one
+	VATExempt     bool     // outside of the EU VAT area
+	CustomsExempt bool     // outside of the EU customs territory
+	PostalCodes   []string // prefixes of the postal codes of the territory within the postal system of Country
+}
+
+// SpecialTerritories is ordered so that longer postal code prefixes come before shorter ones of the same country (Saint-Martin 97150 before Guadeloupe 971).
+var SpecialTerritories = []Territory{
+	{ID: "aland", Country: FI, Code: "AX", VATExempt: true, PostalCodes: []string{"22"}},
+	{ID: "busingen", Country: DE, VATExempt: true, CustomsExempt: true, PostalCodes: []string{"78266"}},
+	{ID: "campione", Country: IT, VATExempt: true, PostalCodes: []string{"22061"}},
+	{ID: "canary-islands", Country: ES, VATExempt: true, PostalCodes: []string{"35", "38"}},
+	{ID: "ceuta", Country: ES, VATExempt: true, CustomsExempt: true, PostalCodes: []string{"51"}},
+	{ID: "heligoland", Country: DE, VATExempt: true, CustomsExempt: true, PostalCodes: []string{"27498"}},
+	{ID: "livigno", Country: IT, VATExempt: true, CustomsExempt: true, PostalCodes: []string{"23041"}},
+	{ID: "melilla", Country: ES, VATExempt: true, CustomsExempt: true, PostalCodes: []string{"52"}},
+	{ID: "mount-athos", Country: GR, VATExempt: true, PostalCodes: []string{"63086", "63087"}},
+	{ID: "saint-martin", Country: FR, Code: "MF", VATExempt: true, CustomsExempt: true, PostalCodes: []string{"97150"}},
+	{ID: "guadeloupe", Country: FR, Code: "GP", VATExempt: true, PostalCodes: []string{"971"}},
+	{ID: "martinique", Country: FR, Code: "MQ", VATExempt: true, PostalCodes: []string{"972"}},
+	{ID: "french-guiana", Country: FR, Code: "GF", VATExempt: true, PostalCodes: []string{"973"}},
+	{ID: "reunion", Country: FR, Code: "RE", VATExempt: true, PostalCodes: []string{"974"}},
+	{ID: "mayotte", Country: FR, Code: "YT", VATExempt: true, PostalCodes: []string{"976"}},
+}
+
+// vatAreaOf contains non-EU countries which are treated as part of the VAT area of a member state.
+var vatAreaOf = map[Country]Country{
+	"MC": FR, // Monaco
+}
+
+// GetTerritory returns the special territory with the given ID.
+func GetTerritory(id string) (Territory, bool) {
+	for _, t := range SpecialTerritories {
+		if t.ID == id {
+			return t, true
+		}
+	}
+	return Territory{}, false
+}
+
+func (t Territory) TranslateName(l lang.Lang) string {
+	if t.Code != "" {
+		return t.Code.TranslateName(l)
+	}
+	switch t.ID {
+	case "busingen":
+		return l.Tr("Büsingen am Hochrhein")
+	case "campione":
+		return l.Tr("Campione d'Italia")
+	case "canary-islands":
+		return l.Tr("Canary Islands")
+	case "ceuta":
+		return l.Tr("Ceuta")
+	case "heligoland":
+		return l.Tr("Heligoland")
+	case "livigno":
+		return l.Tr("Livigno")
+	case "melilla":
+		return l.Tr("Melilla")
+	case "mount-athos":
+		return l.Tr("Mount Athos")
+	default:
+		return t.ID
+	}
+}
+
+// VATTreatment is the result of ResolveVAT.
+type VATTreatment struct {
+	Country   Country    // member state whose VAT applies, empty if Export is true
+	Territory *Territory // special territory, if any
+	Rates     VATRates   // nil if Export is true
+	Export    bool       // whether the address is outside of the EU VAT area
+}
+
+// ResolveVAT determines the VAT treatment of a supply to an address, which can be outside of the EU VAT area although its country is a member state (e.g. Heligoland or the Canary Islands).
+// Special territories are recognized by their own ISO 3166-1 code (e.g. "AX" for Åland) or by the postal code of the member state (e.g. DE 27498 for Heligoland).
+// Monaco is treated as part of the French VAT area.
+func ResolveVAT(country Country, postalCode string, date time.Time) VATTreatment {
+	if member, ok := vatAreaOf[country]; ok {
+		country = member
+	}
+
+	postalCode = normalizePostalCode(postalCode)
+	for i := range SpecialTerritories {
+		t := &SpecialTerritories[i]
+		if t.Code != "" && country == t.Code || country == t.Country && t.matches(postalCode) {
+			if t.VATExempt {
+				return VATTreatment{Territory: t, Export: true}
+			}
+			return VATTreatment{Country: t.Country, Territory: t, Rates: t.Country.VATAt(date)}
+		}
+	}
+
+	if !InEuropeanUnion(country) {
+		return VATTreatment{Export: true}
+	}
+	return VATTreatment{Country: country, Rates: country.VATAt(date)}
+}
+
+func (t *Territory) matches(postalCode string) bool {
+	if postalCode == "" {
+		return false
+	}
+	for _, prefix := range t.PostalCodes {
+		if strings.HasPrefix(postalCode, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizePostalCode returns the digits of postalCode, so "AX-22100", "D-27498" and "630 86" are recognized.
+func normalizePostalCode(postalCode string) string {
+	return strings.Map(func(r rune) rune {
+		if '0' <= r && r <= '9' {
+			return r
+		}
+		return -1
+	}, postalCode)
+}
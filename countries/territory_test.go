@@ -0,0 +1,61 @@
+package countries
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResolveVAT(t *testing.T) {
+	tests := []struct {
+		country    Country
+		postalCode string
+		territory  string
+		export     bool
+		vatCountry Country
+	}{
+		{DE, "10115", "", false, DE},
+		{DE, "", "", false, DE},
+		{DE, "27498", "heligoland", true, ""},
+		{DE, "D-27498", "heligoland", true, ""},
+		{DE, "78266", "busingen", true, ""},
+		{ES, "28001", "", false, ES},
+		{ES, "35001", "canary-islands", true, ""},
+		{ES, "38001", "canary-islands", true, ""},
+		{ES, "51001", "ceuta", true, ""},
+		{ES, "52001", "melilla", true, ""},
+		{GR, "630 86", "mount-athos", true, ""},
+		{GR, "10431", "", false, GR},
+		{IT, "23041", "livigno", true, ""},
+		{IT, "22061", "campione", true, ""},
+		{IT, "00118", "", false, IT},
+		{FI, "22100", "aland", true, ""},
+		{FI, "AX-22100", "aland", true, ""},
+		{"AX", "22100", "aland", true, ""},
+		{FI, "00100", "", false, FI},
+		{FR, "97150", "saint-martin", true, ""},
+		{FR, "97110", "guadeloupe", true, ""},
+		{"GP", "97110", "guadeloupe", true, ""},
+		{FR, "97200", "martinique", true, ""},
+		{FR, "97300", "french-guiana", true, ""},
+		{FR, "97400", "reunion", true, ""},
+		{FR, "97600", "mayotte", true, ""},
+		{FR, "75001", "", false, FR},
+		{"MC", "98000", "", false, FR},
+		{CH, "8000", "", true, ""},
+		{"US", "10001", "", true, ""},
+	}
+
+	for _, test := range tests {
+		got := ResolveVAT(test.country, test.postalCode, time.Now())
+		var territory string
+		if got.Territory != nil {
+			territory = got.Territory.ID
+		}
+		if territory != test.territory || got.Export != test.export || got.Country != test.vatCountry {
+			t.Fatalf("%s %s: got %+v", test.country, test.postalCode, got)
+		}
+		if got.Export != (got.Rates == nil) {
+			t.Fatalf("%s %s: got export %t and rates %v", test.country, test.postalCode, got.Export, got.Rates)
+		}
+	}
+}
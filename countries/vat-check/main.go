@@ -0,0 +1,154 @@
+// Command vat-check prints the VAT rates of European Union countries in a format similar to https://europa.eu/youreurope/business/taxation/vat/vat-rules-rates/index_en.htm#shortcut-5 so we can easily diff it.
+//
+// By default, the rates which apply today are printed. Use -date to print the rates which apply at another date, and -override to apply a hotfix file (see countries.LoadVATOverrides).
+//
+// If a file is given, vat-check compares our rates to it and reports every mismatch. The file can be a saved copy of the "Your Europe" page (HTML) or a response of the TEDB VAT rates web service (XML):
+//
+//	vat-check -date 2024-01-01 vat-rules-rates.html
+//
+// The exit status is 1 if there are mismatches.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dys2p/eco/countries"
+)
+
+func main() {
+	log.SetFlags(0)
+
+	dateFlag := flag.String("date", time.Now().Format("2006-01-02"), "use the rates which apply at this date (YYYY-MM-DD)")
+	overrideFlag := flag.String("override", "", "load VAT rate overrides from this JSON file")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] [eu-table.html|tedb.xml]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	date, err := time.Parse("2006-01-02", *dateFlag)
+	if err != nil {
+		log.Fatalf("parsing date: %v", err)
+	}
+	if *overrideFlag != "" {
+		if err := countries.LoadVATOverrides(*overrideFlag); err != nil {
+			log.Fatalf("loading overrides: %v", err)
+		}
+	}
+
+	switch flag.NArg() {
+	case 0:
+		for _, c := range countries.EuropeanUnion {
+			fmt.Println(ratesOf(c.VATAt(date)).String(c))
+		}
+	case 1:
+		data, err := os.ReadFile(flag.Arg(0))
+		if err != nil {
+			log.Fatal(err)
+		}
+		table, err := parseTable(data)
+		if err != nil {
+			log.Fatalf("parsing %s: %v", flag.Arg(0), err)
+		}
+		mismatches := compare(date, table)
+		for _, mismatch := range mismatches {
+			fmt.Println(mismatch)
+		}
+		if len(mismatches) > 0 {
+			os.Exit(1)
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// rates contains the VAT rates of a country in percent, like in the EU table. Zero means that the rate does not exist.
+type rates struct {
+	Standard     float64
+	Reduced      []float64 // sorted
+	SuperReduced float64
+	Parking      float64
+}
+
+func ratesOf(vat countries.VATRates) rates {
+	var result = rates{
+		Standard:     percent(vat[countries.RateStandard]),
+		SuperReduced: percent(vat[countries.RateSuperReduced]),
+		Parking:      percent(vat[countries.RateParking]),
+	}
+	for _, key := range []countries.Rate{countries.RateReduced1, countries.RateReduced2} {
+		if r, ok := vat[key]; ok {
+			result.addReduced(percent(r))
+		}
+	}
+	return result
+}
+
+func (r *rates) addReduced(value float64) {
+	if value > 0 && !slices.Contains(r.Reduced, value) {
+		r.Reduced = append(r.Reduced, value)
+		slices.Sort(r.Reduced)
+	}
+}
+
+// String returns the rates in the format of the EU table, separated by tabs.
+func (r rates) String(c countries.Country) string {
+	return strings.Join([]string{string(c), fmtPercent(r.Standard), fmtPercents(r.Reduced), fmtPercent(r.SuperReduced), fmtPercent(r.Parking)}, "\t")
+}
+
+func compare(date time.Time, table map[countries.Country]rates) []string {
+	var mismatches []string
+	for _, c := range countries.EuropeanUnion {
+		theirs, ok := table[c]
+		if !ok {
+			mismatches = append(mismatches, fmt.Sprintf("%s: missing in table", c))
+			continue
+		}
+		ours := ratesOf(c.VATAt(date))
+		if ours.Standard != theirs.Standard {
+			mismatches = append(mismatches, fmt.Sprintf("%s: standard rate: ours %s, table %s", c, fmtPercent(ours.Standard), fmtPercent(theirs.Standard)))
+		}
+		if !slices.Equal(ours.Reduced, theirs.Reduced) {
+			mismatches = append(mismatches, fmt.Sprintf("%s: reduced rates: ours %s, table %s", c, fmtPercents(ours.Reduced), fmtPercents(theirs.Reduced)))
+		}
+		if ours.SuperReduced != theirs.SuperReduced {
+			mismatches = append(mismatches, fmt.Sprintf("%s: super-reduced rate: ours %s, table %s", c, fmtPercent(ours.SuperReduced), fmtPercent(theirs.SuperReduced)))
+		}
+		if ours.Parking != theirs.Parking {
+			mismatches = append(mismatches, fmt.Sprintf("%s: parking rate: ours %s, table %s", c, fmtPercent(ours.Parking), fmtPercent(theirs.Parking)))
+		}
+	}
+	return mismatches
+}
+
+// percent converts a fraction to percent, rounded to two decimal places in order to avoid floating point noise.
+func percent(f float64) float64 {
+	return math.Round(f*100.0*100.0) / 100.0
+}
+
+func fmtPercent(f float64) string {
+	if f == 0 {
+		return "-"
+	}
+	return strconv.FormatFloat(f, 'g', 3, 64)
+}
+
+func fmtPercents(fs []float64) string {
+	if len(fs) == 0 {
+		return "-"
+	}
+	var strs = make([]string, len(fs))
+	for i, f := range fs {
+		strs[i] = fmtPercent(f)
+	}
+	return strings.Join(strs, " / ")
+}
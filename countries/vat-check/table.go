@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/dys2p/eco/countries"
+	"golang.org/x/net/html"
+)
+
+// parseTable detects the file format and parses the VAT rates of European Union countries.
+func parseTable(data []byte) (map[countries.Country]rates, error) {
+	var table map[countries.Country]rates
+	var err error
+	if bytes.Contains(data, []byte("vatRateResults")) {
+		table, err = parseTEDB(data)
+	} else {
+		table, err = parseHTML(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(table) == 0 {
+		return nil, errors.New("no VAT rates found")
+	}
+	return table, nil
+}
+
+// tableCountry returns the country with the given code, translating the ISO 639-1 code "EL" which is used for Greece.
+func tableCountry(code string) (countries.Country, bool) {
+	code = strings.TrimSpace(code)
+	if code == "EL" {
+		code = "GR"
+	}
+	return countries.Get(countries.EuropeanUnion, code)
+}
+
+// parseHTML parses the table on https://europa.eu/youreurope/business/taxation/vat/vat-rules-rates/index_en.htm#shortcut-5.
+// Each row contains the country name, country code, standard rate, reduced rates, super-reduced rate and parking rate.
+func parseHTML(data []byte) (map[countries.Country]rates, error) {
+	doc, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	var table = make(map[countries.Country]rates)
+	var walk func(node *html.Node) error
+	walk = func(node *html.Node) error {
+		if node.Type == html.ElementNode && node.Data == "tr" {
+			var cells []string
+			for child := node.FirstChild; child != nil; child = child.NextSibling {
+				if child.Type == html.ElementNode && (child.Data == "td" || child.Data == "th") {
+					cells = append(cells, textContent(child))
+				}
+			}
+			// find country code column, rates follow
+			for i, cell := range cells {
+				country, ok := tableCountry(cell)
+				if !ok || len(cells) < i+5 {
+					continue
+				}
+				r, err := parseRow(cells[i+1 : i+5])
+				if err != nil {
+					return fmt.Errorf("%s: %w", country, err)
+				}
+				table[country] = r
+				break
+			}
+			return nil
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if err := walk(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return table, walk(doc)
+}
+
+func textContent(node *html.Node) string {
+	var sb strings.Builder
+	var walk func(node *html.Node)
+	walk = func(node *html.Node) {
+		if node.Type == html.TextNode {
+			sb.WriteString(node.Data)
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(node)
+	return strings.TrimSpace(sb.String())
+}
+
+// parseRow parses the cells standard rate, reduced rates, super-reduced rate and parking rate.
+func parseRow(cells []string) (rates, error) {
+	var r rates
+	var err error
+	if r.Standard, err = parsePercent(cells[0]); err != nil {
+		return r, err
+	}
+	for _, field := range strings.Split(cells[1], "/") {
+		reduced, err := parsePercent(field)
+		if err != nil {
+			return r, err
+		}
+		r.addReduced(reduced)
+	}
+	if r.SuperReduced, err = parsePercent(cells[2]); err != nil {
+		return r, err
+	}
+	if r.Parking, err = parsePercent(cells[3]); err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+// parsePercent parses values like "20", "5.5", "13,5 %" and dashes, which mean that the rate does not exist.
+func parsePercent(s string) (float64, error) {
+	s = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "%"))
+	switch s {
+	case "", "-", "–", "—":
+		return 0, nil
+	}
+	f, err := strconv.ParseFloat(strings.ReplaceAll(s, ",", "."), 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing rate: %w", err)
+	}
+	return percent(f / 100.0), nil
+}
+
+// tedbResult is an element of a response of the TEDB VAT rates web service (retrieveVatRates).
+type tedbResult struct {
+	MemberState string `xml:"memberState"`
+	Type        string `xml:"type"` // STANDARD or REDUCED
+	Rate        struct {
+		Type  string  `xml:"type"` // e.g. DEFAULT, REDUCED_RATE, SUPER_REDUCED_RATE, PARKING_RATE, EXEMPTED
+		Value float64 `xml:"value"`
+	} `xml:"rate"`
+}
+
+// parseTEDB parses the vatRateResults elements of a TEDB XML response, regardless of the SOAP envelope and namespaces around them.
+func parseTEDB(data []byte) (map[countries.Country]rates, error) {
+	var table = make(map[countries.Country]rates)
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "vatRateResults" {
+			continue
+		}
+		var result tedbResult
+		if err := decoder.DecodeElement(&result, &start); err != nil {
+			return nil, err
+		}
+		country, ok := tableCountry(result.MemberState)
+		if !ok || result.Rate.Value <= 0 {
+			continue
+		}
+		r := table[country]
+		value := percent(result.Rate.Value / 100.0)
+		switch {
+		case result.Type == "STANDARD":
+			r.Standard = value
+		case strings.Contains(result.Rate.Type, "SUPER_REDUCED"):
+			r.SuperReduced = value
+		case strings.Contains(result.Rate.Type, "PARKING"):
+			r.Parking = value
+		case strings.Contains(result.Rate.Type, "REDUCED"):
+			r.addReduced(value)
+		}
+		table[country] = r
+	}
+	return table, nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/dys2p/eco/countries"
+)
+
+func TestParseTable(t *testing.T) {
+	tests := []struct {
+		file string
+		want map[countries.Country]rates
+	}{
+		{
+			"testdata/vat-rules-rates.html",
+			map[countries.Country]rates{
+				countries.AT: {Standard: 20, Reduced: []float64{10, 13}, Parking: 13},
+				countries.DE: {Standard: 19, Reduced: []float64{7}},
+				countries.GR: {Standard: 24, Reduced: []float64{6, 13}},
+				countries.FR: {Standard: 20, Reduced: []float64{5.5, 10}, SuperReduced: 2.1},
+				countries.IE: {Standard: 23, Reduced: []float64{9, 13.5}, SuperReduced: 4.8, Parking: 13.5},
+				countries.LU: {Standard: 17, Reduced: []float64{8}, SuperReduced: 3, Parking: 14},
+			},
+		},
+		{
+			"testdata/tedb.xml",
+			map[countries.Country]rates{
+				countries.DE: {Standard: 19, Reduced: []float64{7}},
+				countries.GR: {Standard: 24, Reduced: []float64{6, 13}},
+				countries.FR: {Standard: 20, Reduced: []float64{5.5, 10}, SuperReduced: 2.1},
+				countries.IE: {Standard: 23, Parking: 13.5},
+			},
+		},
+	}
+	for _, test := range tests {
+		data, err := os.ReadFile(test.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := parseTable(data)
+		if err != nil {
+			t.Fatalf("%s: %v", test.file, err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Fatalf("%s: got %v, want %v", test.file, got, test.want)
+		}
+	}
+
+	if _, err := parseTable([]byte("<html><body><p>maintenance</p></body></html>")); err == nil {
+		t.Fatal("got nil error, want error")
+	}
+	if _, err := parseTable([]byte("<table><tr><td>DE</td><td>19</td><td>seven</td><td>-</td><td>-</td></tr></table>")); err == nil {
+		t.Fatal("invalid rate: got nil error, want error")
+	}
+}
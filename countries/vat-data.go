@@ -0,0 +1,127 @@
+package countries
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"time"
+)
+
+// VAT rates are from: https://europa.eu/youreurope/business/taxation/vat/vat-rules-rates/index_en.htm#shortcut-5.
+// Note that the ISO 3166-1 code for Greece is "GR", but the VAT rate table uses its ISO 639-1 code "EL".
+//
+//go:embed vat-rates.json
+var vatRatesJSON []byte
+
+// vatHistory contains the VAT rates of each country, ordered by ValidFrom. The first period of each country has no ValidFrom date and applies to all earlier dates.
+var vatHistory = mustParseVATHistory(vatRatesJSON)
+
+func mustParseVATHistory(data []byte) map[Country][]VATPeriod {
+	history, err := parseVATHistory(data)
+	if err != nil {
+		panic(fmt.Sprintf("parsing embedded vat rates: %v", err))
+	}
+	if err := validateVATHistory(history); err != nil {
+		panic(fmt.Sprintf("validating embedded vat rates: %v", err))
+	}
+	return history
+}
+
+func parseVATHistory(data []byte) (map[Country][]VATPeriod, error) {
+	var history map[Country][]VATPeriod
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil, err
+	}
+	for country, periods := range history {
+		for _, period := range periods {
+			if period.ValidFrom == "" {
+				continue
+			}
+			if _, err := time.Parse("2006-01-02", period.ValidFrom); err != nil {
+				return nil, fmt.Errorf("%s: invalid date: %w", country, err)
+			}
+		}
+	}
+	return history, nil
+}
+
+// vatRates contains the valid keys of VATRates.
+var vatRates = []Rate{RateStandard, RateReduced1, RateReduced2, RateSuperReduced, RateParking, RateZero}
+
+func validateVATHistory(history map[Country][]VATPeriod) error {
+	for country, periods := range history {
+		if !InEuropeanUnion(country) {
+			return fmt.Errorf("%s: not a member state of the European Union", country)
+		}
+		if len(periods) == 0 {
+			return fmt.Errorf("%s: no rates", country)
+		}
+		for i, period := range periods {
+			if i == 0 && period.ValidFrom != "" {
+				return fmt.Errorf("%s: first period must not have a valid_from date", country)
+			}
+			if i > 0 && period.ValidFrom <= periods[i-1].ValidFrom {
+				return fmt.Errorf("%s: periods are not ordered by date: %s", country, period.ValidFrom)
+			}
+			if _, ok := period.Rates[RateStandard]; !ok {
+				return fmt.Errorf("%s %s: missing standard rate", country, period.ValidFrom)
+			}
+			for rate, value := range period.Rates {
+				if !slices.Contains(vatRates, rate) {
+					return fmt.Errorf("%s %s: unknown rate: %s", country, period.ValidFrom, rate)
+				}
+				if value < 0 || value >= 1 {
+					return fmt.Errorf("%s %s: %s rate out of range: %v", country, period.ValidFrom, rate, value)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// LoadVATOverrides reads a JSON file in the format of the embedded vat-rates.json and merges it into the VAT rates, for example as a hotfix for a rate change which has not been released yet.
+// A period replaces the existing period with the same ValidFrom date, other periods are added.
+// If the file is invalid, the VAT rates remain unchanged.
+//
+// LoadVATOverrides is not safe for concurrent use with the VAT functions, so call it on startup.
+func LoadVATOverrides(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	overrides, err := parseVATHistory(data)
+	if err != nil {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	var merged = make(map[Country][]VATPeriod, len(vatHistory))
+	for country, periods := range vatHistory {
+		merged[country] = periods
+	}
+	for country, periods := range overrides {
+		var byDate = make(map[string]VATPeriod)
+		for _, period := range merged[country] {
+			byDate[period.ValidFrom] = period
+		}
+		for _, period := range periods {
+			byDate[period.ValidFrom] = period
+		}
+		var result = make([]VATPeriod, 0, len(byDate))
+		for _, period := range byDate {
+			result = append(result, period)
+		}
+		sort.Slice(result, func(i, j int) bool {
+			return result[i].ValidFrom < result[j].ValidFrom
+		})
+		merged[country] = result
+	}
+
+	if err := validateVATHistory(merged); err != nil {
+		return fmt.Errorf("validating %s: %w", path, err)
+	}
+	vatHistory = merged
+	return nil
+}
@@ -1,6 +1,6 @@
 package countries
 
-import "math"
+import "time"
 
 type Rate string
 
@@ -10,18 +10,22 @@ const (
 	RateReduced2     Rate = "reduced-2"
 	RateSuperReduced Rate = "super-reduced"
 	RateParking      Rate = "parking"
+	RateZero         Rate = "zero" // zero rate with the right to deduct input VAT, e.g. for books in Ireland
 )
 
 type VATRates map[Rate]float64
 
-func Convert(value int, src Country, srcRate Rate, dst Country, dstRate Rate) int {
-	if src == dst && srcRate == dstRate {
-		return value
-	}
-	srcVal := float64(value)
-	netVal, _ := src.VAT().Net(srcVal, srcRate)
-	dstVal, _ := dst.VAT().Gross(netVal, dstRate)
-	return int(math.Round(dstVal))
+// VATPeriod contains the VAT rates of a country which are valid from a date (format "2006-01-02") until the ValidFrom date of the next period.
+type VATPeriod struct {
+	ValidFrom string   `json:"valid_from,omitempty"`
+	Note      string   `json:"note,omitempty"`
+	Rates     VATRates `json:"rates"`
+}
+
+// Convert converts a gross value from the source country and rate to the destination country and rate, using the VAT rates which apply at the given date.
+// It is a shorthand for ConvertMoney with RoundHalfUp.
+func Convert(value int, src Country, srcRate Rate, dst Country, dstRate Rate, date time.Time) int {
+	return int(ConvertMoney(Money(value), src, srcRate, dst, dstRate, date, RoundHalfUp))
 }
 
 // Gross returns the gross of the given net amount using the given VAT rate key. The boolean return value indicates if the rate has been found. If it is not found, the maximum rate is used.
@@ -2,7 +2,9 @@ package countries
 
 import (
 	"math"
+	"os"
 	"testing"
+	"time"
 )
 
 const epsilon = 1e-9
@@ -14,14 +16,17 @@ func TestConvert(t *testing.T) {
 		srcRate Rate
 		dst     Country
 		dstRate Rate
+		date    string
 		want    int
 	}{
-		{100, DE, RateStandard, DE, RateStandard, 100},
-		{100, DE, RateReduced1, DK, RateStandard, 117},
+		{100, DE, RateStandard, DE, RateStandard, "2024-01-01", 100},
+		{100, DE, RateReduced1, DK, RateStandard, "2024-01-01", 117},
+		{100, DE, RateReduced1, DK, RateStandard, "2020-10-01", 119}, // temporary 5 % rate in Germany
 	}
 
 	for _, test := range tests {
-		if got := Convert(test.value, test.src, test.srcRate, test.dst, test.dstRate); got != test.want {
+		date, _ := time.Parse("2006-01-02", test.date)
+		if got := Convert(test.value, test.src, test.srcRate, test.dst, test.dstRate, date); got != test.want {
 			t.Fatalf("convert: got %d, want %d", got, test.want)
 		}
 	}
@@ -72,3 +77,103 @@ func TestVATRate(t *testing.T) {
 		}
 	}
 }
+
+func TestVATAt(t *testing.T) {
+	tests := []struct {
+		country Country
+		date    string
+		rate    Rate
+		want    float64
+	}{
+		{DE, "2019-01-01", RateStandard, 0.19},
+		{DE, "2020-06-30", RateStandard, 0.19},
+		{DE, "2020-07-01", RateStandard, 0.16},
+		{DE, "2020-12-31", RateReduced1, 0.05},
+		{DE, "2021-01-01", RateStandard, 0.19},
+		{LU, "2023-06-01", RateStandard, 0.16},
+		{LU, "2024-06-01", RateStandard, 0.17},
+		{NonEU, "2024-06-01", RateStandard, 0},
+	}
+
+	for _, test := range tests {
+		date, _ := time.Parse("2006-01-02", test.date)
+		if got, _ := test.country.VATAt(date).Rate(test.rate); got != test.want {
+			t.Fatalf("VATAt %s %s: got %f, want %f", test.country, test.date, got, test.want)
+		}
+	}
+}
+
+func TestLoadVATOverrides(t *testing.T) {
+	defer func(original map[Country][]VATPeriod) {
+		vatHistory = original
+	}(vatHistory)
+
+	path := t.TempDir() + "/overrides.json"
+
+	// invalid overrides, must not change anything
+	for _, invalid := range []string{
+		`{"DE": [{"valid_from": "2030-01-01", "rates": {"standard": 20}}]}`,
+		`{"DE": [{"valid_from": "2030-01-01", "rates": {"standard": 0.20, "reduced": 0.07}}]}`, // unknown rate key
+		`{"EL": [{"rates": {"standard": 0.24}}]}`,                                              // ISO 639-1 code instead of ISO 3166-1
+	} {
+		if err := os.WriteFile(path, []byte(invalid), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := LoadVATOverrides(path); err == nil {
+			t.Fatalf("%s: got nil error, want validation error", invalid)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte(`{"DE": [{"valid_from": "2030-01-01", "rates": {"standard": 0.20, "reduced-1": 0.07}}]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadVATOverrides(path); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		date string
+		want float64
+	}{
+		{"2020-08-01", 0.16}, // existing periods are kept
+		{"2029-12-31", 0.19},
+		{"2030-01-01", 0.20},
+	}
+	for _, test := range tests {
+		date, _ := time.Parse("2006-01-02", test.date)
+		if got := DE.VATAt(date)[RateStandard]; got != test.want {
+			t.Fatalf("%s: got %f, want %f", test.date, got, test.want)
+		}
+	}
+}
+
+func TestRateFor(t *testing.T) {
+	tests := []struct {
+		country  Country
+		category Category
+		want     Rate
+	}{
+		{DE, CategoryBooks, RateReduced1},
+		{DK, CategoryBooks, RateStandard},
+		{ES, CategoryBooks, RateSuperReduced},
+		{IE, CategoryBooks, RateZero},
+		{DE, CategoryChildrensClothing, RateStandard},
+		{IE, CategoryChildrensClothing, RateZero},
+		{NonEU, CategoryFood, RateStandard},
+		{DE, Category("unknown"), RateStandard},
+	}
+	for _, test := range tests {
+		if got := RateFor(test.country, test.category); got != test.want {
+			t.Fatalf("%s %s: got %s, want %s", test.country, test.category, got, test.want)
+		}
+	}
+
+	// all mapped rates must exist, else VATRates.Rate falls back to the maximum rate
+	for category, mapping := range vatCategories {
+		for country, rate := range mapping {
+			if _, ok := country.VAT()[rate]; !ok {
+				t.Fatalf("%s %s: rate %s does not exist", category, country, rate)
+			}
+		}
+	}
+}
@@ -0,0 +1,416 @@
+package countries
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidVATID = errors.New("invalid VAT identification number")
+
+// VATIDPrefix returns the prefix of VAT identification numbers of the country. It equals the ISO 3166-1 code, except for Greece, which uses "EL".
+func (c Country) VATIDPrefix() string {
+	if c == GR {
+		return "EL"
+	}
+	return string(c)
+}
+
+// NormalizeVATID removes whitespace, dots, dashes and slashes from id and converts it to upper case.
+func NormalizeVATID(id string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '.', '-', '/':
+			return -1
+		}
+		if 'a' <= r && r <= 'z' {
+			r -= 'a' - 'A'
+		}
+		return r
+	}, id)
+}
+
+// ParseVATID normalizes the VAT identification number of a European Union member state and checks its syntax and checksum.
+// It returns the country and the number without prefix, or ErrInvalidVATID.
+func ParseVATID(id string) (Country, string, error) {
+	id = NormalizeVATID(id)
+	if len(id) < 3 {
+		return "", "", ErrInvalidVATID
+	}
+	prefix, number := id[:2], id[2:]
+	for _, c := range EuropeanUnion {
+		if c.VATIDPrefix() != prefix {
+			continue
+		}
+		if check := vatIDChecks[c]; check == nil || !check(number) {
+			return "", "", ErrInvalidVATID
+		}
+		return c, number, nil
+	}
+	return "", "", ErrInvalidVATID
+}
+
+// ValidVATID returns true if id is a syntactically valid VAT identification number of a European Union member state. It does not check whether the number has been issued, see package vies for that.
+func ValidVATID(id string) bool {
+	_, _, err := ParseVATID(id)
+	return err == nil
+}
+
+// ReverseCharge returns whether a B2B supply from seller to a buyer with the given VAT identification number is subject to the reverse charge procedure,
+// i.e. the seller invoices without VAT. This is the case if seller and buyer are different European Union member states, and if vatID is valid and has been issued by the buyer country.
+//
+// ReverseCharge only checks the syntax of vatID. Verify it with VIES before.
+func ReverseCharge(seller, buyer Country, vatID string) bool {
+	if seller == buyer || !InEuropeanUnion(seller) || !InEuropeanUnion(buyer) {
+		return false
+	}
+	country, _, err := ParseVATID(vatID)
+	return err == nil && country == buyer
+}
+
+// Checksum algorithms are mostly documented at https://ec.europa.eu/taxation_customs/vies/faq.html and in python-stdnum.
+var vatIDChecks = map[Country]func(string) bool{
+	AT: func(n string) bool {
+		if len(n) != 9 || n[0] != 'U' || !isDigits(n[1:]) {
+			return false
+		}
+		sum := 0
+		for i, d := range digits(n[1:8]) {
+			if i%2 == 1 {
+				d *= 2
+				d = d/10 + d%10
+			}
+			sum += d
+		}
+		return (10-(sum+4)%10)%10 == digit(n[8])
+	},
+	BE: func(n string) bool {
+		if len(n) == 9 {
+			n = "0" + n // old format
+		}
+		if len(n) != 10 || !isDigits(n) || (n[0] != '0' && n[0] != '1') {
+			return false
+		}
+		value, _ := strconv.Atoi(n[:8])
+		check, _ := strconv.Atoi(n[8:])
+		return 97-value%97 == check
+	},
+	BG: func(n string) bool {
+		if !isDigits(n) {
+			return false
+		}
+		ds := digits(n)
+		switch len(n) {
+		case 9: // legal entity
+			check := weightedSum(ds[:8], 1, 2, 3, 4, 5, 6, 7, 8) % 11
+			if check == 10 {
+				check = weightedSum(ds[:8], 3, 4, 5, 6, 7, 8, 9, 10) % 11 % 10
+			}
+			return check == ds[8]
+		case 10: // physical person, foreigner or other
+			person := weightedSum(ds[:9], 2, 4, 8, 5, 10, 9, 7, 3, 6) % 11 % 10
+			foreigner := weightedSum(ds[:9], 21, 19, 17, 13, 11, 9, 7, 3, 1) % 10
+			other := (11 - weightedSum(ds[:9], 4, 3, 2, 7, 6, 5, 4, 3, 2)%11) % 11
+			return person == ds[9] || foreigner == ds[9] || (other != 10 && other == ds[9])
+		}
+		return false
+	},
+	CY: func(n string) bool {
+		if len(n) != 9 || !isDigits(n[:8]) || n[0] == '2' || n[8] < 'A' || n[8] > 'Z' || n[:2] == "12" {
+			return false
+		}
+		translation := []int{1, 0, 5, 7, 9, 13, 15, 17, 19, 21}
+		sum := 0
+		for i, d := range digits(n[:8]) {
+			if i%2 == 0 {
+				sum += translation[d]
+			} else {
+				sum += d
+			}
+		}
+		return int(n[8]-'A') == sum%26
+	},
+	CZ: func(n string) bool {
+		if !isDigits(n) {
+			return false
+		}
+		switch len(n) {
+		case 8: // legal entity
+			if n[0] == '9' {
+				return false
+			}
+			check := (11 - weightedSum(digits(n[:7]), 8, 7, 6, 5, 4, 3, 2)%11) % 11
+			if check == 0 {
+				check = 1
+			}
+			return check%10 == digit(n[7])
+		case 9: // birth number before 1954, no checksum
+			return true
+		case 10: // birth number
+			value, _ := strconv.ParseInt(n, 10, 64)
+			return value%11 == 0 || (value/10%11 == 10 && value%10 == 0)
+		}
+		return false
+	},
+	DE: func(n string) bool {
+		return len(n) == 9 && isDigits(n) && n[0] != '0' && mod11_10(n)
+	},
+	DK: func(n string) bool {
+		return len(n) == 8 && isDigits(n) && n[0] != '0' && weightedSum(digits(n), 2, 7, 6, 5, 4, 3, 2, 1)%11 == 0
+	},
+	EE: func(n string) bool {
+		return len(n) == 9 && isDigits(n) && n[:2] == "10" && weightedSum(digits(n), 3, 7, 1, 3, 7, 1, 3, 7, 1)%10 == 0
+	},
+	ES: func(n string) bool {
+		if len(n) != 9 || !isDigits(n[1:8]) {
+			return false
+		}
+		const dniLetters = "TRWAGMYFPDXBNJZSQVHLCKE"
+		switch first := n[0]; {
+		case '0' <= first && first <= '9': // DNI
+			value, _ := strconv.Atoi(n[:8])
+			return n[8] == dniLetters[value%23]
+		case first == 'X' || first == 'Y' || first == 'Z': // NIE
+			value, _ := strconv.Atoi(string('0'+first-'X') + n[1:8])
+			return n[8] == dniLetters[value%23]
+		case first == 'K' || first == 'L' || first == 'M': // natural persons without DNI or NIE
+			value, _ := strconv.Atoi(n[1:8])
+			return n[8] == dniLetters[value%23]
+		case strings.IndexByte("ABCDEFGHJNPQRSUVW", first) >= 0: // legal entity (CIF)
+			sum := 0
+			for i, d := range digits(n[1:8]) {
+				if i%2 == 0 {
+					d *= 2
+					d = d/10 + d%10
+				}
+				sum += d
+			}
+			check := (10 - sum%10) % 10
+			return n[8] == byte('0'+check) || n[8] == "JABCDEFGHI"[check]
+		}
+		return false
+	},
+	FI: func(n string) bool {
+		return len(n) == 8 && isDigits(n) && weightedSum(digits(n), 7, 9, 10, 5, 8, 4, 2, 1)%11 == 0
+	},
+	FR: func(n string) bool {
+		if len(n) != 11 || !isDigits(n[2:]) || !luhn(n[2:]) {
+			return false
+		}
+		if !isDigits(n[:2]) {
+			return isAlphanumeric(n[:2]) // new style check characters, no public algorithm
+		}
+		siren, _ := strconv.Atoi(n[2:])
+		key, _ := strconv.Atoi(n[:2])
+		return key == (12+3*(siren%97))%97
+	},
+	GR: func(n string) bool {
+		if len(n) == 8 {
+			n = "0" + n // old format
+		}
+		if len(n) != 9 || !isDigits(n) {
+			return false
+		}
+		return weightedSum(digits(n[:8]), 256, 128, 64, 32, 16, 8, 4, 2)%11%10 == digit(n[8])
+	},
+	HR: func(n string) bool {
+		return len(n) == 11 && isDigits(n) && mod11_10(n)
+	},
+	HU: func(n string) bool {
+		return len(n) == 8 && isDigits(n) && weightedSum(digits(n), 9, 7, 3, 1, 9, 7, 3, 1)%10 == 0
+	},
+	IE: func(n string) bool {
+		if len(n) == 8 && isDigits(n[:1]) && !isDigits(n[1:2]) && isDigits(n[2:7]) {
+			n = "0" + n[2:7] + n[:1] + n[7:] // old format: digit, letter or + or *, five digits, letter
+		}
+		if (len(n) != 8 && len(n) != 9) || !isDigits(n[:7]) {
+			return false
+		}
+		sum := weightedSum(digits(n[:7]), 8, 7, 6, 5, 4, 3, 2)
+		if len(n) == 9 {
+			switch {
+			case n[8] == 'W':
+			case 'A' <= n[8] && n[8] <= 'I':
+				sum += 9 * int(n[8]-'A'+1)
+			default:
+				return false
+			}
+		}
+		return n[7] == "WABCDEFGHIJKLMNOPQRSTUV"[sum%23]
+	},
+	IT: func(n string) bool {
+		if len(n) != 11 || !isDigits(n) || n[:7] == "0000000" {
+			return false
+		}
+		office, _ := strconv.Atoi(n[7:10])
+		if (office < 1 || office > 100) && office != 120 && office != 121 && office != 888 && office != 999 {
+			return false
+		}
+		return luhn(n)
+	},
+	LT: func(n string) bool {
+		if !isDigits(n) || (len(n) != 9 || n[7] != '1') && (len(n) != 12 || n[10] != '1') {
+			return false
+		}
+		ds := digits(n)
+		sum := 0
+		for i, d := range ds[:len(ds)-1] {
+			sum += (i%9 + 1) * d
+		}
+		check := sum % 11
+		if check == 10 {
+			sum = 0
+			for i, d := range ds[:len(ds)-1] {
+				sum += ((i+2)%9 + 1) * d
+			}
+			check = sum % 11
+		}
+		return check%10 == ds[len(ds)-1]
+	},
+	LU: func(n string) bool {
+		if len(n) != 8 || !isDigits(n) {
+			return false
+		}
+		value, _ := strconv.Atoi(n[:6])
+		check, _ := strconv.Atoi(n[6:])
+		return value%89 == check
+	},
+	LV: func(n string) bool {
+		if len(n) != 11 || !isDigits(n) {
+			return false
+		}
+		if n[0] > '3' { // legal entity
+			return weightedSum(digits(n), 9, 1, 4, 8, 3, 10, 2, 5, 7, 6, 1)%11 == 3
+		}
+		return true // natural person, personal codes issued since 2017 have no checksum
+	},
+	MT: func(n string) bool {
+		return len(n) == 8 && isDigits(n) && n[0] != '0' && weightedSum(digits(n), 3, 4, 6, 7, 8, 9, 10, 1)%37 == 0
+	},
+	NL: func(n string) bool {
+		if len(n) != 12 || !isDigits(n[:9]) || n[9] != 'B' || !isDigits(n[10:]) || n[10:] == "00" {
+			return false
+		}
+		// legacy: based on the citizen service number
+		if weightedSum(digits(n[:9]), 9, 8, 7, 6, 5, 4, 3, 2, -1)%11 == 0 {
+			return true
+		}
+		// since 2020: ISO 7064 Mod 97-10 over "NL" plus the number, letters converted to numbers
+		var sb strings.Builder
+		for _, r := range "NL" + n {
+			if 'A' <= r && r <= 'Z' {
+				sb.WriteString(strconv.Itoa(int(r-'A') + 10))
+			} else {
+				sb.WriteRune(r)
+			}
+		}
+		remainder := 0
+		for _, d := range digits(sb.String()) {
+			remainder = (remainder*10 + d) % 97
+		}
+		return remainder == 1
+	},
+	PL: func(n string) bool {
+		return len(n) == 10 && isDigits(n) && weightedSum(digits(n[:9]), 6, 5, 7, 2, 3, 4, 5, 6, 7)%11 == digit(n[9])
+	},
+	PT: func(n string) bool {
+		if len(n) != 9 || !isDigits(n) || n[0] == '0' {
+			return false
+		}
+		return (11-weightedSum(digits(n[:8]), 9, 8, 7, 6, 5, 4, 3, 2)%11)%11%10 == digit(n[8])
+	},
+	RO: func(n string) bool {
+		if len(n) < 2 || len(n) > 10 || !isDigits(n) || n[0] == '0' {
+			return false
+		}
+		padded := strings.Repeat("0", 10-len(n)) + n
+		return weightedSum(digits(padded[:9]), 7, 5, 3, 2, 1, 7, 5, 3, 2)*10%11%10 == digit(padded[9])
+	},
+	SE: func(n string) bool {
+		return len(n) == 12 && isDigits(n) && n[10:] == "01" && luhn(n[:10])
+	},
+	SI: func(n string) bool {
+		if len(n) != 8 || !isDigits(n) || n[0] == '0' {
+			return false
+		}
+		check := 11 - weightedSum(digits(n[:7]), 8, 7, 6, 5, 4, 3, 2)%11
+		return check != 11 && check%10 == digit(n[7])
+	},
+	SK: func(n string) bool {
+		if len(n) != 10 || !isDigits(n) || n[0] == '0' || strings.IndexByte("234789", n[2]) < 0 {
+			return false
+		}
+		value, _ := strconv.ParseInt(n, 10, 64)
+		return value%11 == 0
+	},
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func isAlphanumeric(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if (s[i] < '0' || s[i] > '9') && (s[i] < 'A' || s[i] > 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+func digit(b byte) int {
+	return int(b - '0')
+}
+
+// digits converts a string of digits, which must have been checked with isDigits, to a slice of ints.
+func digits(s string) []int {
+	var ds = make([]int, len(s))
+	for i := range s {
+		ds[i] = digit(s[i])
+	}
+	return ds
+}
+
+func weightedSum(ds []int, weights ...int) int {
+	sum := 0
+	for i := range ds {
+		sum += ds[i] * weights[i]
+	}
+	return sum
+}
+
+// luhn checks the Luhn checksum of a string of digits.
+func luhn(s string) bool {
+	sum := 0
+	for i, d := range digits(s) {
+		if (len(s)-i)%2 == 0 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// mod11_10 checks the ISO 7064 Mod 11,10 checksum of a string of digits.
+func mod11_10(s string) bool {
+	product := 10
+	for _, d := range digits(s[:len(s)-1]) {
+		sum := (d + product) % 10
+		if sum == 0 {
+			sum = 10
+		}
+		product = (2 * sum) % 11
+	}
+	return (11-product)%10 == digit(s[len(s)-1])
+}
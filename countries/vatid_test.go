@@ -0,0 +1,87 @@
+package countries
+
+import "testing"
+
+func TestParseVATID(t *testing.T) {
+	valid := map[string]Country{
+		"ATU13585627":       AT,
+		"BE403019261":       BE,
+		"BE 0403.019.261":   BE,
+		"BG 175 074 752":    BG,
+		"CY-10259033P":      CY,
+		"CZ 25123891":       CZ,
+		"DE136695976":       DE,
+		"de 136 695 976":    DE,
+		"DK 13585628":       DK,
+		"EE 100 931 558":    EE,
+		"EL 094259216":      GR,
+		"ES A13585625":      ES,
+		"ES 54362315K":      ES,
+		"FI 20774740":       FI,
+		"FR 40 303 265 045": FR,
+		"HR 33392005961":    HR,
+		"HU-12892312":       HU,
+		"IE 6433435F":       IE,
+		"IE 8Z49289F":       IE,
+		"IT 00743110157":    IT,
+		"LT 119511515":      LT,
+		"LU 150 274 42":     LU,
+		"LV 4000 3521 600":  LV,
+		"MT 1167-9112":      MT,
+		"NL004495445B01":    NL,
+		"PL 8567346215":     PL,
+		"PT 501 964 843":    PT,
+		"RO 185 472 90":     RO,
+		"SE 123456789701":   SE,
+		"SI 5022 3054":      SI,
+		"SK 202 274 96 19":  SK,
+	}
+	for id, want := range valid {
+		got, _, err := ParseVATID(id)
+		if err != nil {
+			t.Fatalf("%s: got error %v", id, err)
+		}
+		if got != want {
+			t.Fatalf("%s: got %s, want %s", id, got, want)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"DE",
+		"DE136695977", // wrong checksum
+		"GR094259216", // Greece uses EL
+		"ATU13585626",
+		"FR 41 303 265 045",
+		"NL004495445B00",
+		"XX123456789",
+		"CH123456789",
+	}
+	for _, id := range invalid {
+		if ValidVATID(id) {
+			t.Fatalf("%s: got valid", id)
+		}
+	}
+}
+
+func TestReverseCharge(t *testing.T) {
+	tests := []struct {
+		seller Country
+		buyer  Country
+		vatID  string
+		want   bool
+	}{
+		{DE, AT, "ATU13585627", true},
+		{DE, GR, "EL094259216", true},
+		{DE, DE, "DE136695976", false}, // domestic
+		{DE, FR, "ATU13585627", false}, // other country
+		{DE, AT, "ATU13585626", false}, // invalid
+		{DE, CH, "CHE123456789", false},
+		{DE, AT, "", false},
+	}
+	for _, test := range tests {
+		if got := ReverseCharge(test.seller, test.buyer, test.vatID); got != test.want {
+			t.Fatalf("%s %s %s: got %t, want %t", test.seller, test.buyer, test.vatID, got, test.want)
+		}
+	}
+}
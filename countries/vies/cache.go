@@ -0,0 +1,62 @@
+package vies
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/dys2p/eco/countries"
+)
+
+// Cache is a Checker which caches the results of another Checker. Errors are not cached.
+type Cache struct {
+	checker Checker
+	ttl     time.Duration
+	lock    sync.Mutex
+	entries map[string]cacheEntry // key: normalized VAT ID
+}
+
+type cacheEntry struct {
+	result  Result
+	expires time.Time
+}
+
+func NewCache(checker Checker, ttl time.Duration) *Cache {
+	return &Cache{
+		checker: checker,
+		ttl:     ttl,
+		entries: make(map[string]cacheEntry),
+	}
+}
+
+func (cache *Cache) Check(ctx context.Context, vatID string) (*Result, error) {
+	key := countries.NormalizeVATID(vatID)
+
+	cache.lock.Lock()
+	entry, ok := cache.entries[key]
+	cache.lock.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		result := entry.result // copy
+		return &result, nil
+	}
+
+	result, err := cache.checker.Check(ctx, vatID)
+	if err != nil {
+		return nil, err
+	}
+
+	cache.lock.Lock()
+	cache.entries[key] = cacheEntry{
+		result:  *result,
+		expires: time.Now().Add(cache.ttl),
+	}
+	// remove expired entries, so the map does not grow forever
+	for k, e := range cache.entries {
+		if time.Now().After(e.expires) {
+			delete(cache.entries, k)
+		}
+	}
+	cache.lock.Unlock()
+
+	return result, nil
+}
@@ -0,0 +1,66 @@
+package vies
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// FakeServer is a local stand-in for the VIES SOAP web service.
+type FakeServer struct {
+	*httptest.Server
+	Requests atomic.Int64
+}
+
+// NewFakeServer starts a server which answers like VIES. The given VAT IDs (normalized, with prefix, e.g. "ATU13585627") are valid and map to the company name, all other VAT IDs are not valid.
+// VAT IDs with the prefix "CY" get a MS_UNAVAILABLE fault, so you can test the error handling.
+//
+// Set Client.URL to the URL of the server. Close the server when you are done.
+func NewFakeServer(valid map[string]string) *FakeServer {
+	fake := &FakeServer{}
+	fake.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fake.Requests.Add(1)
+
+		var req struct {
+			CountryCode string `xml:"Body>checkVat>countryCode"`
+			VATNumber   string `xml:"Body>checkVat>vatNumber"`
+		}
+		if err := xml.NewDecoder(r.Body).Decode(&req); err != nil || req.CountryCode == "" {
+			writeFault(w, "INVALID_INPUT")
+			return
+		}
+		if req.CountryCode == "CY" {
+			writeFault(w, "MS_UNAVAILABLE")
+			return
+		}
+
+		name, ok := valid[req.CountryCode+req.VATNumber]
+		address := "---"
+		if !ok {
+			name = "---"
+		} else {
+			address = "Example Street 1, 12345 Example City"
+		}
+
+		w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+		fmt.Fprintf(w, `<env:Envelope xmlns:env="http://schemas.xmlsoap.org/soap/envelope/"><env:Header/><env:Body><ns2:checkVatResponse xmlns:ns2="urn:ec.europa.eu:taxud:vies:services:checkVat:types"><ns2:countryCode>%s</ns2:countryCode><ns2:vatNumber>%s</ns2:vatNumber><ns2:requestDate>%s</ns2:requestDate><ns2:valid>%t</ns2:valid><ns2:name>%s</ns2:name><ns2:address>%s</ns2:address></ns2:checkVatResponse></env:Body></env:Envelope>`,
+			escape(req.CountryCode), escape(req.VATNumber), time.Now().Format("2006-01-02-07:00"), ok, escape(name), escape(address))
+	}))
+	return fake
+}
+
+func writeFault(w http.ResponseWriter, faultString string) {
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+	fmt.Fprintf(w, `<env:Envelope xmlns:env="http://schemas.xmlsoap.org/soap/envelope/"><env:Header/><env:Body><env:Fault><faultcode>env:Server</faultcode><faultstring>%s</faultstring></env:Fault></env:Body></env:Envelope>`, faultString)
+}
+
+func escape(s string) string {
+	var sb strings.Builder
+	xml.EscapeText(&sb, []byte(s))
+	return sb.String()
+}
@@ -0,0 +1,141 @@
+// Package vies checks VAT identification numbers with the VAT Information Exchange System (VIES) of the European Commission.
+//
+// Wrap the SOAP client in a cache, so repeated checks of a customer don't hit VIES:
+//
+//	checker := vies.NewCache(&vies.Client{}, 24*time.Hour)
+//	result, err := checker.Check(ctx, "ATU13585627")
+//
+// In tests and during development, use NewFakeServer as a local stand-in for VIES.
+package vies
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dys2p/eco/countries"
+)
+
+const DefaultURL = "https://ec.europa.eu/taxation_customs/vies/services/checkVatService"
+
+// ErrUnavailable is returned if VIES or the service of the member state is temporarily unavailable. The VAT identification number could not be checked, so try again later.
+var ErrUnavailable = errors.New("vies unavailable")
+
+// A Checker checks whether a VAT identification number is valid and has been issued.
+type Checker interface {
+	Check(ctx context.Context, vatID string) (*Result, error)
+}
+
+type Result struct {
+	CountryCode string // "EL" for Greece
+	VATNumber   string
+	RequestDate string
+	Valid       bool
+	Name        string // may be "---" if the member state does not disclose it
+	Address     string
+}
+
+// Client is a Checker which calls the VIES SOAP web service.
+type Client struct {
+	URL        string       // default: DefaultURL
+	HTTPClient *http.Client // default: client with a timeout of 30 seconds
+}
+
+var defaultHTTPClient = &http.Client{
+	Timeout: 30 * time.Second,
+}
+
+type envelope struct {
+	XMLName xml.Name `xml:"http://schemas.xmlsoap.org/soap/envelope/ Envelope"`
+	Body    struct {
+		CheckVatResponse *checkVatResponse `xml:"urn:ec.europa.eu:taxud:vies:services:checkVat:types checkVatResponse"`
+		Fault            *struct {
+			String string `xml:"faultstring"`
+		} `xml:"Fault"`
+	} `xml:"Body"`
+}
+
+type checkVatResponse struct {
+	CountryCode string `xml:"countryCode"`
+	VATNumber   string `xml:"vatNumber"`
+	RequestDate string `xml:"requestDate"`
+	Valid       bool   `xml:"valid"`
+	Name        string `xml:"name"`
+	Address     string `xml:"address"`
+}
+
+const requestTemplate = `<?xml version="1.0" encoding="UTF-8"?>
+<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/" xmlns:urn="urn:ec.europa.eu:taxud:vies:services:checkVat:types">
+<soapenv:Header/>
+<soapenv:Body>
+<urn:checkVat>
+<urn:countryCode>%s</urn:countryCode>
+<urn:vatNumber>%s</urn:vatNumber>
+</urn:checkVat>
+</soapenv:Body>
+</soapenv:Envelope>`
+
+// Check calls VIES. If vatID is syntactically invalid, it returns countries.ErrInvalidVATID without calling VIES.
+func (client *Client) Check(ctx context.Context, vatID string) (*Result, error) {
+	country, number, err := countries.ParseVATID(vatID)
+	if err != nil {
+		return nil, err
+	}
+
+	url := client.URL
+	if url == "" {
+		url = DefaultURL
+	}
+	httpClient := client.HTTPClient
+	if httpClient == nil {
+		httpClient = defaultHTTPClient
+	}
+
+	// number has been validated, so it needs no escaping
+	body := fmt.Sprintf(requestTemplate, country.VATIDPrefix(), number)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "text/xml; charset=utf-8")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("%w: reading response: %v", ErrUnavailable, err)
+	}
+	var env envelope
+	if err := xml.NewDecoder(bytes.NewReader(data)).Decode(&env); err != nil {
+		return nil, fmt.Errorf("%w: status %d: decoding response: %v", ErrUnavailable, resp.StatusCode, err)
+	}
+	if fault := env.Body.Fault; fault != nil {
+		switch fault.String {
+		case "INVALID_INPUT":
+			return nil, countries.ErrInvalidVATID
+		default: // e.g. MS_UNAVAILABLE, SERVICE_UNAVAILABLE, TIMEOUT, MS_MAX_CONCURRENT_REQ
+			return nil, fmt.Errorf("%w: %s", ErrUnavailable, fault.String)
+		}
+	}
+	if env.Body.CheckVatResponse == nil {
+		return nil, fmt.Errorf("%w: status %d: empty response", ErrUnavailable, resp.StatusCode)
+	}
+	r := env.Body.CheckVatResponse
+	return &Result{
+		CountryCode: r.CountryCode,
+		VATNumber:   r.VATNumber,
+		RequestDate: r.RequestDate,
+		Valid:       r.Valid,
+		Name:        strings.TrimSpace(r.Name),
+		Address:     strings.TrimSpace(r.Address),
+	}, nil
+}
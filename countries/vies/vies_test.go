@@ -0,0 +1,76 @@
+package vies
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dys2p/eco/countries"
+)
+
+func TestCheck(t *testing.T) {
+	fake := NewFakeServer(map[string]string{
+		"ATU13585627": "Example GmbH",
+		"EL094259216": "Example A.E.",
+	})
+	defer fake.Close()
+
+	client := &Client{URL: fake.URL}
+
+	tests := []struct {
+		vatID   string
+		valid   bool
+		name    string
+		wantErr error
+	}{
+		{"ATU 1358 5627", true, "Example GmbH", nil},
+		{"EL094259216", true, "Example A.E.", nil},
+		{"DE136695976", false, "---", nil},
+		{"DE136695977", false, "", countries.ErrInvalidVATID},
+		{"CY10259033P", false, "", ErrUnavailable},
+	}
+	for _, test := range tests {
+		result, err := client.Check(context.Background(), test.vatID)
+		if !errors.Is(err, test.wantErr) {
+			t.Fatalf("%s: got error %v, want %v", test.vatID, err, test.wantErr)
+		}
+		if err != nil {
+			continue
+		}
+		if result.Valid != test.valid || result.Name != test.name {
+			t.Fatalf("%s: got %+v", test.vatID, result)
+		}
+	}
+}
+
+func TestCache(t *testing.T) {
+	fake := NewFakeServer(map[string]string{
+		"ATU13585627": "Example GmbH",
+	})
+	defer fake.Close()
+
+	cache := NewCache(&Client{URL: fake.URL}, time.Hour)
+	for _, vatID := range []string{"ATU13585627", "atu 13585627", "ATU13585627"} {
+		result, err := cache.Check(context.Background(), vatID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Valid {
+			t.Fatalf("%s: got invalid", vatID)
+		}
+	}
+	if got := fake.Requests.Load(); got != 1 {
+		t.Fatalf("got %d requests, want 1", got)
+	}
+
+	// errors are not cached
+	for i := 0; i < 2; i++ {
+		if _, err := cache.Check(context.Background(), "CY10259033P"); !errors.Is(err, ErrUnavailable) {
+			t.Fatalf("got error %v, want %v", err, ErrUnavailable)
+		}
+	}
+	if got := fake.Requests.Load(); got != 3 {
+		t.Fatalf("got %d requests, want 3", got)
+	}
+}
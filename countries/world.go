@@ -0,0 +1,70 @@
+package countries
+
+import (
+	"slices"
+
+	"github.com/dys2p/eco/lang"
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+)
+
+// World contains all officially assigned ISO 3166-1 alpha-2 codes. Use it for shipping countries.
+var World = []Country{
+	"AD", "AE", "AF", "AG", "AI", "AL", "AM", "AO", "AQ", "AR", "AS", "AT", "AU", "AW", "AX", "AZ", "BA", "BB", "BD", "BE",
+	"BF", "BG", "BH", "BI", "BJ", "BL", "BM", "BN", "BO", "BQ", "BR", "BS", "BT", "BV", "BW", "BY", "BZ", "CA", "CC", "CD",
+	"CF", "CG", "CH", "CI", "CK", "CL", "CM", "CN", "CO", "CR", "CU", "CV", "CW", "CX", "CY", "CZ", "DE", "DJ", "DK", "DM",
+	"DO", "DZ", "EC", "EE", "EG", "EH", "ER", "ES", "ET", "FI", "FJ", "FK", "FM", "FO", "FR", "GA", "GB", "GD", "GE", "GF",
+	"GG", "GH", "GI", "GL", "GM", "GN", "GP", "GQ", "GR", "GS", "GT", "GU", "GW", "GY", "HK", "HM", "HN", "HR", "HT", "HU",
+	"ID", "IE", "IL", "IM", "IN", "IO", "IQ", "IR", "IS", "IT", "JE", "JM", "JO", "JP", "KE", "KG", "KH", "KI", "KM", "KN",
+	"KP", "KR", "KW", "KY", "KZ", "LA", "LB", "LC", "LI", "LK", "LR", "LS", "LT", "LU", "LV", "LY", "MA", "MC", "MD", "ME",
+	"MF", "MG", "MH", "MK", "ML", "MM", "MN", "MO", "MP", "MQ", "MR", "MS", "MT", "MU", "MV", "MW", "MX", "MY", "MZ", "NA",
+	"NC", "NE", "NF", "NG", "NI", "NL", "NO", "NP", "NR", "NU", "NZ", "OM", "PA", "PE", "PF", "PG", "PH", "PK", "PL", "PM",
+	"PN", "PR", "PS", "PT", "PW", "PY", "QA", "RE", "RO", "RS", "RU", "RW", "SA", "SB", "SC", "SD", "SE", "SG", "SH", "SI",
+	"SJ", "SK", "SL", "SM", "SN", "SO", "SR", "SS", "ST", "SV", "SX", "SY", "SZ", "TC", "TD", "TF", "TG", "TH", "TJ", "TK",
+	"TL", "TM", "TN", "TO", "TR", "TT", "TV", "TW", "TZ", "UA", "UG", "UM", "US", "UY", "UZ", "VA", "VC", "VE", "VG", "VI",
+	"VN", "VU", "WF", "WS", "YE", "YT", "ZA", "ZM", "ZW",
+}
+
+// EEA contains the members of the European Economic Area: the European Union plus Iceland, Liechtenstein and Norway.
+var EEA = append(slices.Clone(EuropeanUnion), "IS", "LI", "NO")
+
+// SEPA contains the countries of the geographical scope of the Single Euro Payments Area. Note that some special territories of member states are in the SEPA too.
+var SEPA = append(slices.Clone(EEA), "AD", "CH", "GB", "GG", "GI", "IM", "JE", "MC", "PM", "SM", "VA")
+
+// CustomsUnion contains the countries of the customs union of the European Union: the member states, Monaco (which is part of the EU customs territory) and San Marino.
+// Andorra and Turkey are in a customs union with the European Union for some goods only and are not included.
+var CustomsUnion = append(slices.Clone(EuropeanUnion), "MC", "SM")
+
+func InEEA(country Country) bool {
+	return slices.Contains(EEA, country)
+}
+
+func InSEPA(country Country) bool {
+	return slices.Contains(SEPA, country)
+}
+
+func InCustomsUnion(country Country) bool {
+	return slices.Contains(CustomsUnion, country)
+}
+
+// translateCLDR returns the name of a country which is not listed in TranslateName. It looks up the English CLDR name in the gotext catalog of l.
+// If there is no catalog entry, it returns the CLDR name in the language of l.
+func (c Country) translateCLDR(l lang.Lang) string {
+	region, err := language.ParseRegion(string(c))
+	if err != nil {
+		return string(c)
+	}
+	english := display.English.Regions().Name(region)
+	if english == "" {
+		return string(c)
+	}
+	if l.Printer != nil {
+		if translated := l.Tr(english); translated != english {
+			return translated
+		}
+	}
+	if name := display.Regions(l.Tag).Name(region); name != "" {
+		return name
+	}
+	return english
+}
@@ -0,0 +1,59 @@
+package countries
+
+import (
+	"testing"
+
+	"github.com/dys2p/eco/lang"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+func TestWorld(t *testing.T) {
+	if len(World) != 249 {
+		t.Fatalf("got %d countries, want 249", len(World))
+	}
+	for _, c := range All {
+		if _, ok := Get(World, string(c)); !ok {
+			t.Fatalf("%s is missing in World", c)
+		}
+	}
+}
+
+func TestTranslateNameCLDR(t *testing.T) {
+	de := lang.Lang{
+		Printer: message.NewPrinter(language.German),
+		Tag:     language.German,
+	}
+	tests := map[Country]string{
+		"US":  "Vereinigte Staaten",
+		"AX":  "Ålandinseln",
+		"XX":  "XX",
+		NonEU: "non-EU",
+	}
+	for c, want := range tests {
+		if got := c.TranslateName(de); got != want {
+			t.Fatalf("%s: got %s, want %s", c, got, want)
+		}
+	}
+}
+
+func TestGroups(t *testing.T) {
+	tests := []struct {
+		country  Country
+		eea      bool
+		sepa     bool
+		customs  bool
+		european bool
+	}{
+		{DE, true, true, true, true},
+		{"NO", true, true, false, false},
+		{CH, false, true, false, false},
+		{"MC", false, true, true, false},
+		{"US", false, false, false, false},
+	}
+	for _, test := range tests {
+		if InEEA(test.country) != test.eea || InSEPA(test.country) != test.sepa || InCustomsUnion(test.country) != test.customs || InEuropeanUnion(test.country) != test.european {
+			t.Fatalf("%s: wrong groups", test.country)
+		}
+	}
+}
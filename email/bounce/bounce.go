@@ -0,0 +1,190 @@
+// Package bounce parses delivery status notifications (DSN, RFC 3464) and abuse feedback reports (ARF, RFC 5965), and matches them to sent messages by their Message-ID.
+//
+// MakeMessage sets Message.MessageID, so store it after SendMessage has returned. email.Queue sets it when the message is queued (configure Queue.From), not when it is delivered.
+// You can also set it with email.NewMessageID before. Then process bounces from a mailbox or from the MTA:
+//
+//	processor := bounce.Processor{
+//		Domain: "example.com",
+//		Callback: func(report *bounce.Report) error {
+//			return db.FlagUndeliverable(report.MessageID, report.Failed()) // MessageID may be empty
+//		},
+//	}
+//
+//	// Postfix /etc/aliases: bounces: "|/usr/local/bin/shop process-bounce"
+//	err := processor.Process(os.Stdin)
+package bounce
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+var ErrNotReport = errors.New("not a delivery status notification or feedback report")
+
+// report types
+const (
+	TypeDSN = "dsn"
+	TypeARF = "arf"
+)
+
+type Report struct {
+	Type         string // TypeDSN or TypeARF
+	MessageID    string // Message-ID of the original message, with angle brackets, empty if the report does not contain the original headers
+	ReportingMTA string // DSN only
+	FeedbackType string // ARF only, for example "abuse"
+	Recipients   []Recipient
+}
+
+type Recipient struct {
+	Address        string // DSN: Final-Recipient, ARF: Original-Rcpt-To or To of the original message
+	Action         string // DSN only: "failed", "delayed", "delivered", "relayed" or "expanded"
+	Status         string // DSN only: for example "5.1.1"
+	DiagnosticCode string // DSN only: for example "550 5.1.1 User unknown"
+}
+
+// Failed returns whether delivery to the recipient has failed permanently.
+func (r Recipient) Failed() bool {
+	return r.Action == "failed" || strings.HasPrefix(r.Status, "5.")
+}
+
+// Failed returns the addresses of the recipients whose delivery has failed permanently.
+func (report *Report) Failed() []string {
+	var result []string
+	for _, r := range report.Recipients {
+		if r.Failed() {
+			result = append(result, r.Address)
+		}
+	}
+	return result
+}
+
+// Parse parses a DSN or ARF message. It returns ErrNotReport for other messages.
+func Parse(r io.Reader) (*Report, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, err
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/report" {
+		return nil, ErrNotReport
+	}
+
+	var report = &Report{}
+	switch strings.ToLower(params["report-type"]) {
+	case "delivery-status", "global-delivery-status":
+		report.Type = TypeDSN
+	case "feedback-report":
+		report.Type = TypeARF
+	default:
+		return nil, ErrNotReport
+	}
+
+	var original textproto.MIMEHeader
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		switch partType {
+		case "message/delivery-status", "message/global-delivery-status":
+			if err := report.parseDSN(part); err != nil {
+				return nil, err
+			}
+		case "message/feedback-report":
+			if err := report.parseARF(part); err != nil {
+				return nil, err
+			}
+		case "message/rfc822", "message/global", "text/rfc822-headers", "message/global-headers":
+			// the headers may be truncated, so we take what we get
+			original, _ = textproto.NewReader(bufio.NewReader(part)).ReadMIMEHeader()
+		}
+	}
+
+	if original != nil {
+		report.MessageID = strings.TrimSpace(original.Get("Message-Id"))
+		if report.Type == TypeARF && len(report.Recipients) == 0 {
+			if to, err := mail.ParseAddressList(original.Get("To")); err == nil {
+				for _, addr := range to {
+					report.Recipients = append(report.Recipients, Recipient{Address: addr.Address})
+				}
+			}
+		}
+	}
+	return report, nil
+}
+
+// parseDSN parses the per-message fields and the per-recipient fields of a message/delivery-status part.
+func (report *Report) parseDSN(r io.Reader) error {
+	blocks, err := readBlocks(r)
+	if err != nil {
+		return err
+	}
+	for i, block := range blocks {
+		if i == 0 {
+			report.ReportingMTA = typedValue(block.Get("Reporting-MTA"))
+			continue
+		}
+		report.Recipients = append(report.Recipients, Recipient{
+			Address:        typedValue(block.Get("Final-Recipient")),
+			Action:         strings.ToLower(strings.TrimSpace(block.Get("Action"))),
+			Status:         strings.TrimSpace(block.Get("Status")),
+			DiagnosticCode: typedValue(block.Get("Diagnostic-Code")),
+		})
+	}
+	return nil
+}
+
+// parseARF parses a message/feedback-report part.
+func (report *Report) parseARF(r io.Reader) error {
+	blocks, err := readBlocks(r)
+	if err != nil {
+		return err
+	}
+	for _, block := range blocks {
+		if feedbackType := block.Get("Feedback-Type"); feedbackType != "" {
+			report.FeedbackType = strings.ToLower(strings.TrimSpace(feedbackType))
+		}
+		for _, rcpt := range block.Values("Original-Rcpt-To") {
+			report.Recipients = append(report.Recipients, Recipient{Address: typedValue(rcpt)})
+		}
+	}
+	return nil
+}
+
+// readBlocks reads header blocks which are separated by blank lines. Empty blocks are skipped.
+func readBlocks(r io.Reader) ([]textproto.MIMEHeader, error) {
+	var blocks []textproto.MIMEHeader
+	tp := textproto.NewReader(bufio.NewReader(r))
+	for {
+		block, err := tp.ReadMIMEHeader()
+		if len(block) > 0 {
+			blocks = append(blocks, block)
+		}
+		if err == io.EOF {
+			return blocks, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// typedValue returns the value of a field like "rfc822; user@example.com" without the type and angle brackets.
+func typedValue(value string) string {
+	if _, v, ok := strings.Cut(value, ";"); ok {
+		value = v
+	}
+	value = strings.TrimSpace(value)
+	return strings.TrimSuffix(strings.TrimPrefix(value, "<"), ">")
+}
@@ -0,0 +1,211 @@
+package bounce
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const dsn = `From: MAILER-DAEMON@mx.example.com (Mail Delivery System)
+To: shop@example.com
+Subject: Undelivered Mail Returned to Sender
+MIME-Version: 1.0
+Content-Type: multipart/report; report-type=delivery-status;
+	boundary="B1"
+
+--B1
+Content-Type: text/plain; charset=us-ascii
+
+I'm sorry to have to inform you that your message could not
+be delivered to one or more recipients.
+
+--B1
+Content-Type: message/delivery-status
+
+Reporting-MTA: dns; mx.example.com
+Arrival-Date: Sat, 18 Oct 2026 12:00:00 +0200
+
+Final-Recipient: rfc822; customer@example.net
+Original-Recipient: rfc822;customer@example.net
+Action: failed
+Status: 5.1.1
+Remote-MTA: dns; mx.example.net
+Diagnostic-Code: smtp; 550 5.1.1 <customer@example.net>: Recipient address
+    rejected: User unknown
+
+Final-Recipient: rfc822; other@example.net
+Action: delayed
+Status: 4.4.1
+
+--B1
+Content-Type: text/rfc822-headers
+
+From: shop@example.com
+To: customer@example.net, other@example.net
+Subject: Your order
+Message-ID: <abc123@example.com>
+
+--B1--
+`
+
+const arf = `From: fbl@isp.example
+To: abuse@example.com
+Subject: FW: Your order
+MIME-Version: 1.0
+Content-Type: multipart/report; report-type=feedback-report; boundary="B2"
+
+--B2
+Content-Type: text/plain
+
+This is an email abuse report.
+
+--B2
+Content-Type: message/feedback-report
+
+Feedback-Type: abuse
+User-Agent: SomeGenerator/1.0
+Version: 1
+
+--B2
+Content-Type: message/rfc822
+Content-Disposition: inline
+
+From: shop@example.com
+To: Customer <complainer@isp.example>
+Subject: Your order
+Message-ID: <def456@example.com>
+
+Hello
+--B2--
+`
+
+func TestParse(t *testing.T) {
+	report, err := Parse(strings.NewReader(dsn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Type != TypeDSN || report.MessageID != "<abc123@example.com>" || report.ReportingMTA != "mx.example.com" {
+		t.Fatalf("got %+v", report)
+	}
+	if len(report.Recipients) != 2 {
+		t.Fatalf("got recipients %+v", report.Recipients)
+	}
+	if r := report.Recipients[0]; r.Address != "customer@example.net" || r.Status != "5.1.1" || !strings.HasPrefix(r.DiagnosticCode, "550 5.1.1") {
+		t.Fatalf("got recipient %+v", r)
+	}
+	if failed := report.Failed(); len(failed) != 1 || failed[0] != "customer@example.net" {
+		t.Fatalf("got failed %v", failed)
+	}
+
+	report, err = Parse(strings.NewReader(arf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Type != TypeARF || report.MessageID != "<def456@example.com>" || report.FeedbackType != "abuse" {
+		t.Fatalf("got %+v", report)
+	}
+	if len(report.Recipients) != 1 || report.Recipients[0].Address != "complainer@isp.example" {
+		t.Fatalf("got recipients %+v", report.Recipients)
+	}
+
+	if _, err := Parse(strings.NewReader("From: a@example.com\r\nSubject: Re: Your order\r\n\r\nThanks")); err != ErrNotReport {
+		t.Fatalf("got %v, want %v", err, ErrNotReport)
+	}
+}
+
+func TestProcessMbox(t *testing.T) {
+	other := strings.ReplaceAll(dsn, "abc123@example.com", "xyz@other.example")
+	mbox := "From MAILER-DAEMON Sat Oct 18 12:00:00 2026\n" + dsn +
+		"\nFrom MAILER-DAEMON Sat Oct 18 12:01:00 2026\n" + strings.Replace(arf, "Hello", ">From the shop", 1) +
+		"\nFrom MAILER-DAEMON Sat Oct 18 12:02:00 2026\n" + other
+
+	var ids []string
+	processor := Processor{
+		Domain: "EXAMPLE.com",
+		Callback: func(report *Report) error {
+			ids = append(ids, report.MessageID)
+			return nil
+		},
+	}
+	if err := processor.ProcessMbox(strings.NewReader(mbox)); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(ids, " ") != "<abc123@example.com> <def456@example.com>" {
+		t.Fatalf("got %v", ids)
+	}
+
+	// reports without Message-ID are passed anyway
+	var failed []string
+	processor.Callback = func(report *Report) error {
+		failed = append(failed, report.Failed()...)
+		return nil
+	}
+	withoutHeaders := dsn[:strings.Index(dsn, "--B1\nContent-Type: text/rfc822-headers")] + "--B1--\n"
+	if err := processor.Process(strings.NewReader(withoutHeaders)); err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) != 1 || failed[0] != "customer@example.net" {
+		t.Fatalf("without Message-ID: got %v", failed)
+	}
+
+	if err := (Processor{}).Process(strings.NewReader(dsn)); err != ErrNoCallback {
+		t.Fatalf("got %v, want %v", err, ErrNoCallback)
+	}
+
+	var messages []string
+	readMbox(strings.NewReader(mbox), func(msg []byte) {
+		messages = append(messages, string(msg))
+	})
+	if len(messages) != 3 || !strings.Contains(messages[1], "\nFrom the shop\n") {
+		t.Fatalf("got %d messages", len(messages))
+	}
+}
+
+func TestProcessMaildir(t *testing.T) {
+	dir := t.TempDir()
+	for _, sub := range []string{"new", "cur", "tmp"} {
+		if err := os.Mkdir(filepath.Join(dir, sub), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, content := range map[string]string{
+		"1.dsn":   dsn,
+		"2.arf":   arf,
+		"3.reply": "From: a@example.com\nSubject: Re: Your order\n\nThanks",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, "new", name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// callback fails for the ARF report, so it stays in new
+	processor := Processor{
+		Callback: func(report *Report) error {
+			if report.Type == TypeARF {
+				return errors.New("database locked")
+			}
+			return nil
+		},
+	}
+	if err := processor.ProcessMaildir(dir); err == nil {
+		t.Fatal("got no error")
+	}
+	for sub, want := range map[string]string{
+		"new": "2.arf",
+		"cur": "1.dsn:2,S 3.reply:2,S",
+	} {
+		entries, err := os.ReadDir(filepath.Join(dir, sub))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		if got := strings.Join(names, " "); got != want {
+			t.Fatalf("%s: got %q, want %q", sub, got, want)
+		}
+	}
+}
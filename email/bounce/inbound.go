@@ -0,0 +1,141 @@
+package bounce
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var ErrNoCallback = errors.New("bounce: callback is missing")
+
+// Processor parses incoming messages and calls Callback for each report.
+type Processor struct {
+	Domain   string                     // optional, ignore reports whose Message-ID has another domain (id-right), see email.NewMessageID; reports without Message-ID are passed to Callback anyway
+	Callback func(report *Report) error // required
+}
+
+// Process processes a single message, for example from stdin if it is piped from the MTA. Messages which are not reports are ignored.
+func (p Processor) Process(r io.Reader) error {
+	if p.Callback == nil {
+		return ErrNoCallback
+	}
+	report, err := Parse(r)
+	if errors.Is(err, ErrNotReport) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !p.matches(report) {
+		return nil
+	}
+	return p.Callback(report)
+}
+
+// matches returns whether the report refers to a message of p.Domain. Reports without Message-ID match, because their recipients are known anyway.
+func (p Processor) matches(report *Report) bool {
+	if p.Domain == "" || report.MessageID == "" {
+		return true
+	}
+	at := strings.LastIndex(report.MessageID, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.TrimSuffix(report.MessageID[at+1:], ">")
+	return strings.EqualFold(domain, p.Domain)
+}
+
+// ProcessMbox processes all messages of an mbox file. It continues after errors and returns them joined.
+func (p Processor) ProcessMbox(r io.Reader) error {
+	if p.Callback == nil {
+		return ErrNoCallback
+	}
+	var errs []error
+	err := readMbox(r, func(msg []byte) {
+		if err := p.Process(bytes.NewReader(msg)); err != nil {
+			errs = append(errs, err)
+		}
+	})
+	return errors.Join(append(errs, err)...)
+}
+
+// readMbox splits an mbox into messages. It reverses the quoting of "From " lines (mboxrd).
+func readMbox(r io.Reader, fn func(msg []byte)) error {
+	var msg = &bytes.Buffer{}
+	var started bool
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			switch {
+			case bytes.HasPrefix(line, []byte("From ")):
+				if started {
+					fn(msg.Bytes())
+				}
+				msg = &bytes.Buffer{}
+				started = true
+			case started:
+				if unquoted := bytes.TrimLeft(line, ">"); len(unquoted) < len(line) && bytes.HasPrefix(unquoted, []byte("From ")) {
+					line = line[1:]
+				}
+				msg.Write(line)
+			}
+		}
+		if err == io.EOF {
+			if started {
+				fn(msg.Bytes())
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// ProcessMaildir processes the new messages of a Maildir and moves them to its cur directory. If Callback fails, the message remains in new, so it is processed again next time. Messages which can't be parsed are moved anyway.
+func (p Processor) ProcessMaildir(dir string) error {
+	if p.Callback == nil {
+		return ErrNoCallback
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, "new"))
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		src := filepath.Join(dir, "new", entry.Name())
+		data, err := os.ReadFile(src)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		report, err := Parse(bytes.NewReader(data))
+		switch {
+		case errors.Is(err, ErrNotReport):
+		case err != nil:
+			log.Printf("\033[31m"+"error parsing %s: %v"+"\033[0m", src, err)
+		case p.matches(report):
+			if err := p.Callback(report); err != nil {
+				errs = append(errs, fmt.Errorf("processing %s: %w", src, err))
+				continue
+			}
+		}
+
+		// move to cur and flag as seen
+		if err := os.Rename(src, filepath.Join(dir, "cur", entry.Name()+":2,S")); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,344 @@
+// Package devmail captures emails in memory during development and shows them in a web interface.
+//
+// Use a *Mailbox as email.Emailer, or start its SMTP server with ListenSMTP and point your email.SMTP config (or a sendmail replacement like msmtp) at it:
+//
+//	mailbox := &devmail.Mailbox{From: "shop@example.com"}
+//	listener, err := mailbox.ListenSMTP("127.0.0.1:2525")
+//	...
+//	http.Handle("/devmail/", http.StripPrefix("/devmail", mailbox))
+package devmail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dys2p/eco/email"
+	"github.com/emersion/go-smtp"
+)
+
+// Mailbox stores the most recent messages in memory. The zero value is ready to use.
+type Mailbox struct {
+	From  string // sender for Send and SendMessage, if the message has no From address
+	Limit int    // maximum number of messages, older messages are dropped, default: 100
+
+	lock     sync.Mutex
+	messages []*Message // oldest first
+	lastID   int
+}
+
+// Send implements email.Emailer.
+func (mb *Mailbox) Send(to string, subject string, body []byte) error {
+	return mb.SendMessage(&email.Message{
+		To:      []string{to},
+		Subject: subject,
+		Text:    body,
+	})
+}
+
+// SendMessage implements email.MessageSender. It builds the message like the other email.Emailer implementations do.
+func (mb *Mailbox) SendMessage(msg *email.Message) error {
+	raw, err := email.MakeMessage(mb.From, msg)
+	if err != nil {
+		return err
+	}
+	from := mb.From
+	if msg.From != "" {
+		from = msg.From
+	}
+	mb.Add(from, msg.Recipients(), raw.Bytes())
+	return nil
+}
+
+// Add parses and stores a raw message. A message which can't be parsed is stored anyway, with Message.Err set.
+func (mb *Mailbox) Add(envelopeFrom string, envelopeTo []string, raw []byte) *Message {
+	msg := parse(raw)
+	msg.Received = time.Now()
+	msg.EnvelopeFrom = envelopeFrom
+	msg.EnvelopeTo = envelopeTo
+
+	mb.lock.Lock()
+	defer mb.lock.Unlock()
+	mb.lastID++
+	msg.ID = mb.lastID
+	mb.messages = append(mb.messages, msg)
+	limit := mb.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	if len(mb.messages) > limit {
+		mb.messages = mb.messages[len(mb.messages)-limit:]
+	}
+	return msg
+}
+
+// Messages returns the stored messages, newest first.
+func (mb *Mailbox) Messages() []*Message {
+	mb.lock.Lock()
+	defer mb.lock.Unlock()
+	var result = make([]*Message, len(mb.messages))
+	for i, msg := range mb.messages {
+		result[len(result)-1-i] = msg
+	}
+	return result
+}
+
+// Get returns the message with the given ID.
+func (mb *Mailbox) Get(id int) (*Message, bool) {
+	mb.lock.Lock()
+	defer mb.lock.Unlock()
+	for _, msg := range mb.messages {
+		if msg.ID == id {
+			return msg, true
+		}
+	}
+	return nil, false
+}
+
+// Clear deletes all messages.
+func (mb *Mailbox) Clear() {
+	mb.lock.Lock()
+	defer mb.lock.Unlock()
+	mb.messages = nil
+}
+
+// ListenSMTP starts an SMTP server in the background which adds received messages to the mailbox. The host of addr must be "localhost" or a loopback address, for example "localhost:2525" or "127.0.0.1:2525". Use port 0 for a random port.
+//
+// The server does not offer TLS, so configure your email.SMTP with TLS "none". Any credentials are accepted. Close the returned listener to stop the server.
+func (mb *Mailbox) ListenSMTP(addr string) (net.Listener, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("smtp address must be localhost or a loopback address: %s", addr)
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	server := smtp.NewServer(backend{mb})
+	server.Domain = "localhost"
+	server.AllowInsecureAuth = true
+	server.EnableSMTPUTF8 = true
+	go server.Serve(listener)
+	return listener, nil
+}
+
+type backend struct {
+	mailbox *Mailbox
+}
+
+func (be backend) NewSession(c *smtp.Conn) (smtp.Session, error) {
+	return &session{mailbox: be.mailbox}, nil
+}
+
+type session struct {
+	mailbox *Mailbox
+	from    string
+	to      []string
+}
+
+func (s *session) AuthPlain(username, password string) error {
+	return nil
+}
+
+func (s *session) Mail(from string, opts *smtp.MailOptions) error {
+	s.from = from
+	return nil
+}
+
+func (s *session) Rcpt(to string) error {
+	s.to = append(s.to, to)
+	return nil
+}
+
+func (s *session) Data(r io.Reader) error {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	s.mailbox.Add(s.from, s.to, raw)
+	return nil
+}
+
+func (s *session) Reset() {
+	s.from = ""
+	s.to = nil
+}
+
+func (s *session) Logout() error {
+	return nil
+}
+
+// Message is a captured message.
+type Message struct {
+	ID           int
+	Received     time.Time
+	EnvelopeFrom string
+	EnvelopeTo   []string
+	Raw          []byte
+	Header       mail.Header
+	Parts        []Part // leaf parts of the MIME tree, in depth-first order
+	Err          error  // parsing error
+}
+
+// Part is a decoded leaf part of a message.
+type Part struct {
+	ContentType string // media type, like "text/plain"
+	Charset     string
+	Disposition string // "inline", "attachment" or empty
+	Filename    string
+	ContentID   string // without angle brackets
+	Data        []byte
+}
+
+// IsAttachment returns whether p is an attachment or an inline file, as opposed to a text or HTML body part.
+func (p Part) IsAttachment() bool {
+	return p.Disposition == "attachment" || p.Filename != "" || p.ContentID != ""
+}
+
+var decoder = &mime.WordDecoder{}
+
+// Get returns the decoded value of the header field key.
+func (msg *Message) Get(key string) string {
+	value := msg.Header.Get(key)
+	if decoded, err := decoder.DecodeHeader(value); err == nil {
+		return decoded
+	}
+	return value
+}
+
+// Fields returns the decoded header fields, sorted by key.
+func (msg *Message) Fields() []Field {
+	var keys []string
+	for key := range msg.Header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var fields []Field
+	for _, key := range keys {
+		for _, value := range msg.Header[key] {
+			if decoded, err := decoder.DecodeHeader(value); err == nil {
+				value = decoded
+			}
+			fields = append(fields, Field{key, value})
+		}
+	}
+	return fields
+}
+
+type Field struct {
+	Key   string
+	Value string
+}
+
+// Text returns the index of the first text/plain body part, or -1.
+func (msg *Message) Text() int {
+	return msg.body("text/plain")
+}
+
+// HTML returns the index of the first text/html body part, or -1.
+func (msg *Message) HTML() int {
+	return msg.body("text/html")
+}
+
+func (msg *Message) body(contentType string) int {
+	for i, p := range msg.Parts {
+		if p.ContentType == contentType && !p.IsAttachment() {
+			return i
+		}
+	}
+	return -1
+}
+
+// Attachments returns the indices of the attachments and inline files.
+func (msg *Message) Attachments() []int {
+	var result []int
+	for i, p := range msg.Parts {
+		if p.IsAttachment() {
+			result = append(result, i)
+		}
+	}
+	return result
+}
+
+// parse parses raw. It never returns nil.
+func parse(raw []byte) *Message {
+	msg := &Message{Raw: raw}
+	parsed, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		msg.Err = err
+		return msg
+	}
+	msg.Header = parsed.Header
+	msg.Parts, msg.Err = walk(textproto.MIMEHeader(parsed.Header), parsed.Body)
+	return msg
+}
+
+// walk returns the decoded leaf parts of a MIME entity.
+func walk(header textproto.MIMEHeader, body io.Reader) ([]Part, error) {
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "text/plain; charset=us-ascii"
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		var parts []Part
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			p, err := mr.NextRawPart() // we decode the transfer encoding ourselves
+			if err == io.EOF {
+				return parts, nil
+			}
+			if err != nil {
+				return parts, err
+			}
+			children, err := walk(p.Header, p)
+			parts = append(parts, children...)
+			if err != nil {
+				return parts, err
+			}
+		}
+	}
+
+	var part = Part{
+		ContentType: mediaType,
+		Charset:     params["charset"],
+		ContentID:   strings.Trim(header.Get("Content-ID"), "<>"),
+		Filename:    params["name"],
+	}
+	if disposition, dparams, err := mime.ParseMediaType(header.Get("Content-Disposition")); err == nil {
+		part.Disposition = disposition
+		if dparams["filename"] != "" {
+			part.Filename = dparams["filename"]
+		}
+	}
+
+	switch strings.ToLower(header.Get("Content-Transfer-Encoding")) {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body) // ignores newlines
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+	part.Data, err = io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("decoding part: %w", err)
+	}
+	return []Part{part}, nil
+}
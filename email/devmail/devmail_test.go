@@ -0,0 +1,165 @@
+package devmail
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dys2p/eco/email"
+)
+
+func TestSendMessage(t *testing.T) {
+	mailbox := &Mailbox{From: "Bücherei <shop@example.com>"}
+	err := mailbox.SendMessage(&email.Message{
+		To:      []string{"customer@example.net"},
+		Bcc:     []string{"archive@example.com"},
+		Subject: "Ihre Bestellung",
+		Text:    []byte("Hallo"),
+		HTML:    []byte(`<p>Hallo</p><img src="cid:logo@example.com">`),
+		Attachments: []email.Attachment{
+			{Filename: "logo.png", ContentID: "logo@example.com", Data: []byte("png")},
+			{Filename: "invoice.pdf", Data: []byte("%PDF")},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs := mailbox.Messages()
+	if len(msgs) != 1 {
+		t.Fatalf("got %d messages", len(msgs))
+	}
+	msg := msgs[0]
+	if msg.Err != nil {
+		t.Fatal(msg.Err)
+	}
+	if got := msg.Get("From"); got != `Bücherei <shop@example.com>` {
+		t.Fatalf("got from %q", got)
+	}
+	if strings.Join(msg.EnvelopeTo, " ") != "customer@example.net archive@example.com" {
+		t.Fatalf("got envelope to %v", msg.EnvelopeTo)
+	}
+	if i := msg.Text(); i < 0 || string(msg.Parts[i].Data) != "Hallo" {
+		t.Fatalf("got text part %d", i)
+	}
+	if i := msg.HTML(); i < 0 || !strings.HasPrefix(string(msg.Parts[i].Data), "<p>Hallo</p>") {
+		t.Fatalf("got html part %d", i)
+	}
+	var files []string
+	for _, i := range msg.Attachments() {
+		files = append(files, msg.Parts[i].Filename+":"+string(msg.Parts[i].Data))
+	}
+	if strings.Join(files, " ") != "logo.png:png invoice.pdf:%PDF" {
+		t.Fatalf("got attachments %v", files)
+	}
+}
+
+func TestLimit(t *testing.T) {
+	mailbox := &Mailbox{From: "shop@example.com", Limit: 2}
+	for _, subject := range []string{"a", "b", "c"} {
+		if err := mailbox.Send("customer@example.net", subject, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var subjects []string
+	for _, msg := range mailbox.Messages() {
+		subjects = append(subjects, msg.Get("Subject"))
+	}
+	if strings.Join(subjects, "") != "cb" {
+		t.Fatalf("got %v", subjects)
+	}
+}
+
+func TestListenSMTP(t *testing.T) {
+	mailbox := &Mailbox{}
+	if _, err := mailbox.ListenSMTP("0.0.0.0:0"); err == nil {
+		t.Fatal("listening on all interfaces: got no error")
+	}
+	localhost, err := mailbox.ListenSMTP("localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	localhost.Close()
+	listener, err := mailbox.ListenSMTP("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	mailer := email.SMTP{
+		From:           "shop@example.com",
+		Host:           listener.Addr().String(),
+		TLS:            email.TLSNone,
+		Auth:           email.AuthNone,
+		ConnectTimeout: 5,
+		CommandTimeout: 5,
+	}
+	if err := mailer.Send("jürgen@example.net", "Test", []byte("Hello")); err != nil { // SMTPUTF8
+		t.Fatal(err)
+	}
+	msgs := mailbox.Messages()
+	if len(msgs) != 1 || msgs[0].EnvelopeFrom != "shop@example.com" || msgs[0].Get("Subject") != "Test" {
+		t.Fatalf("got %v", msgs)
+	}
+	if time.Since(msgs[0].Received) > time.Minute {
+		t.Fatal("wrong received time")
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	mailbox := &Mailbox{From: "shop@example.com"}
+	err := mailbox.SendMessage(&email.Message{
+		To:          []string{"customer@example.net"},
+		Subject:     "Ihre <Bestellung>",
+		Text:        []byte("Hallo"),
+		HTML:        []byte(`<img src="cid:logo@example.com"><img src='CID:logo@example.com'><img src=cid:logo@example.com>`),
+		Attachments: []email.Attachment{{Filename: "logo.png", ContentID: "logo@example.com", Data: []byte("png")}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := mailbox.Messages()[0].HTML()
+
+	server := httptest.NewServer(http.StripPrefix("/devmail", mailbox))
+	defer server.Close()
+
+	for _, test := range []struct {
+		path     string
+		status   int
+		contains string
+	}{
+		{"/devmail/", http.StatusOK, `<a href="1">Ihre &lt;Bestellung&gt;</a>`},
+		{"/devmail/1", http.StatusOK, "<pre>Hallo</pre>"},
+		{"/devmail/1/raw", http.StatusOK, "Subject: Ihre <Bestellung>"},
+		{"/devmail/1/" + strconv.Itoa(html), http.StatusOK, `<img src="logo@example.com"><img src='logo@example.com'><img src=logo@example.com>`},
+		{"/devmail/1/logo@example.com", http.StatusOK, "png"},
+		{"/devmail/1/99", http.StatusNotFound, ""},
+		{"/devmail/2", http.StatusNotFound, ""},
+	} {
+		resp, err := http.Get(server.URL + test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != test.status || !strings.Contains(string(body), test.contains) {
+			t.Fatalf("%s: got %d %q", test.path, resp.StatusCode, body)
+		}
+	}
+
+	resp, err := http.Post(server.URL+"/devmail/clear", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if len(mailbox.Messages()) != 0 {
+		t.Fatal("messages have not been cleared")
+	}
+	if resp.StatusCode != http.StatusOK || resp.Request.URL.Path != "/devmail/" {
+		t.Fatalf("clear: redirected to %d %s", resp.StatusCode, resp.Request.URL.Path)
+	}
+}
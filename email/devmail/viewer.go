@@ -0,0 +1,188 @@
+package devmail
+
+import (
+	"bytes"
+	"html/template"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// cidScheme matches the "cid:" scheme of quoted and unquoted attribute values and CSS url() values.
+var cidScheme = regexp.MustCompile(`(?i)([\s"'=(])cid:`)
+
+var viewer = template.Must(template.New("").Parse(`
+{{define "head"}}
+	<!DOCTYPE html>
+	<html>
+		<head>
+			<meta charset="utf-8">
+			<title>devmail</title>
+			<style>
+				body { font-family: sans-serif; margin: 1em 2em; }
+				table { border-collapse: collapse; }
+				td, th { border-bottom: 1px solid #ddd; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+				pre { background: #f4f4f4; padding: 1em; white-space: pre-wrap; }
+				iframe { border: 1px solid #ddd; height: 40em; width: 100%; }
+				.error { color: #b00; }
+			</style>
+		</head>
+		<body>
+{{end}}
+
+{{define "list"}}
+	{{template "head"}}
+	<h1>devmail</h1>
+	<form method="post" action="clear"><button type="submit">Delete all messages</button></form>
+	<table>
+		<tr><th>Received</th><th>From</th><th>To</th><th>Subject</th></tr>
+		{{range .}}
+			<tr>
+				<td>{{.Received.Format "2006-01-02 15:04:05"}}</td>
+				<td>{{.EnvelopeFrom}}</td>
+				<td>{{range $i, $to := .EnvelopeTo}}{{if $i}}, {{end}}{{$to}}{{end}}</td>
+				<td><a href="{{.ID}}">{{with .Get "Subject"}}{{.}}{{else}}(no subject){{end}}</a></td>
+			</tr>
+		{{else}}
+			<tr><td colspan="4">No messages yet.</td></tr>
+		{{end}}
+	</table>
+	</body></html>
+{{end}}
+
+{{define "message"}}
+	{{template "head"}}
+	<p><a href=".">All messages</a> · <a href="{{.ID}}/raw">Raw source</a></p>
+	<h1>{{.Get "Subject"}}</h1>
+	{{with .Err}}<p class="error">Parsing error: {{.}}</p>{{end}}
+	<table>
+		<tr><th>Envelope from</th><td>{{.EnvelopeFrom}}</td></tr>
+		<tr><th>Envelope to</th><td>{{range $i, $to := .EnvelopeTo}}{{if $i}}, {{end}}{{$to}}{{end}}</td></tr>
+		{{range .Fields}}
+			<tr><th>{{.Key}}</th><td>{{.Value}}</td></tr>
+		{{end}}
+	</table>
+	{{$msg := .}}
+	{{if ge .HTML 0}}
+		<h2>HTML</h2>
+		<iframe sandbox src="{{.ID}}/{{.HTML}}"></iframe>
+	{{end}}
+	{{if ge .Text 0}}
+		<h2>Text</h2>
+		<pre>{{printf "%s" (index .Parts .Text).Data}}</pre>
+	{{end}}
+	{{with .Attachments}}
+		<h2>Attachments</h2>
+		<ul>
+			{{range $i := .}}
+				{{with index $msg.Parts $i}}
+					<li><a href="{{$msg.ID}}/{{$i}}">{{with .Filename}}{{.}}{{else}}{{.ContentID}}{{end}}</a> ({{.ContentType}}, {{len .Data}} bytes{{if .ContentID}}, inline{{end}})</li>
+				{{end}}
+			{{end}}
+		</ul>
+	{{end}}
+	</body></html>
+{{end}}
+`))
+
+// ServeHTTP serves the web interface:
+//
+//	/                list of messages
+//	/clear           deletes all messages (POST)
+//	/{id}            message with headers, HTML and text part and a list of attachments
+//	/{id}/raw        raw message
+//	/{id}/{index}    decoded part
+//	/{id}/{cid}      decoded part with the given Content-ID, referenced from the HTML part
+//
+// Mount it with http.StripPrefix and only during development.
+func (mb *Mailbox) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	if path == "" {
+		render(w, "list", mb.Messages())
+		return
+	}
+	if path == "clear" {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		mb.Clear()
+		// http.Redirect would resolve "." against r.URL.Path, which lacks the prefix, so we let the browser resolve it against the original URL
+		location := "./"
+		if strings.HasSuffix(r.URL.Path, "/") {
+			location = "../"
+		}
+		w.Header().Set("Location", location)
+		w.WriteHeader(http.StatusSeeOther)
+		return
+	}
+
+	idStr, rest, _ := strings.Cut(path, "/")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	msg, ok := mb.Get(id)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch rest {
+	case "":
+		render(w, "message", msg)
+	case "raw":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write(msg.Raw)
+	default:
+		part, ok := msg.part(rest)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		contentType := part.ContentType
+		if part.Charset != "" {
+			contentType += "; charset=" + part.Charset
+		}
+		data := part.Data
+		if part.ContentType == "text/html" {
+			// links like "cid:logo@example.com" become relative links to "/{id}/logo@example.com"
+			data = cidScheme.ReplaceAll(data, []byte("$1"))
+		}
+		// captured content is untrusted
+		w.Header().Set("Content-Security-Policy", "sandbox")
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Write(data)
+	}
+}
+
+// part returns a part by index or by Content-ID.
+func (msg *Message) part(ref string) (Part, bool) {
+	if i, err := strconv.Atoi(ref); err == nil {
+		if i < 0 || i >= len(msg.Parts) {
+			return Part{}, false
+		}
+		return msg.Parts[i], true
+	}
+	for _, p := range msg.Parts {
+		if p.ContentID != "" && p.ContentID == ref {
+			return p, true
+		}
+	}
+	return Part{}, false
+}
+
+func render(w http.ResponseWriter, name string, data any) {
+	var buf = &bytes.Buffer{}
+	if err := viewer.ExecuteTemplate(buf, name, data); err != nil {
+		log.Printf("\033[31m"+"error rendering devmail template: %v"+"\033[0m", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(buf.Bytes())
+}
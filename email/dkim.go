@@ -0,0 +1,107 @@
+package email
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/emersion/go-msgauth/dkim"
+)
+
+// dkimHeaders are signed if present. From is required by RFC 6376.
+var dkimHeaders = []string{"From", "Reply-To", "Subject", "Date", "To", "Cc", "Message-ID", "MIME-Version", "Content-Type", "Content-Transfer-Encoding", "List-Unsubscribe"}
+
+// DKIM signs messages with DomainKeys Identified Mail (RFC 6376), using relaxed/relaxed canonicalization. The algorithm is rsa-sha256 or ed25519-sha256 (RFC 8463), depending on the key.
+//
+// Publish the public key in the DNS TXT record "{selector}._domainkey.{domain}", for example:
+//
+//	openssl genrsa -out dkim.pem 2048
+//	openssl rsa -in dkim.pem -pubout -outform der | base64 -w0
+//	→ v=DKIM1; k=rsa; p=MIIBIjANBgkqh...
+type DKIM struct {
+	Domain   string `json:"domain"`
+	Selector string `json:"selector"`
+	KeyPath  string `json:"key_path"` // PEM file with a PKCS #1 RSA key or a PKCS #8 RSA or Ed25519 key
+	signer   crypto.Signer
+}
+
+// LoadDKIM reads the private key.
+func LoadDKIM(domain, selector, keyPath string) (*DKIM, error) {
+	d := &DKIM{
+		Domain:   domain,
+		Selector: selector,
+		KeyPath:  keyPath,
+	}
+	if err := d.load(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *DKIM) load() error {
+	if d.Domain == "" || d.Selector == "" {
+		return errors.New("dkim: domain and selector are required")
+	}
+	data, err := os.ReadFile(d.KeyPath)
+	if err != nil {
+		return fmt.Errorf("dkim: reading key: %w", err)
+	}
+	d.signer, err = parsePrivateKey(data)
+	if err != nil {
+		return fmt.Errorf("dkim: parsing key %s: %w", d.KeyPath, err)
+	}
+	return nil
+}
+
+func parsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		switch key := key.(type) {
+		case *rsa.PrivateKey:
+			return key, nil
+		case ed25519.PrivateKey:
+			return key, nil
+		default:
+			return nil, fmt.Errorf("unsupported key type %T", key)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported PEM type %s", block.Type)
+	}
+}
+
+// Sign returns the message with a DKIM-Signature header prepended.
+func (d *DKIM) Sign(mail *bytes.Buffer) (*bytes.Buffer, error) {
+	if d.signer == nil {
+		return nil, errors.New("dkim: key not loaded, use LoadDKIM")
+	}
+	var signed = &bytes.Buffer{}
+	err := dkim.Sign(signed, mail, &dkim.SignOptions{
+		Domain:                 d.Domain,
+		Selector:               d.Selector,
+		Signer:                 d.signer,
+		Hash:                   crypto.SHA256,
+		HeaderCanonicalization: dkim.CanonicalizationRelaxed,
+		BodyCanonicalization:   dkim.CanonicalizationRelaxed,
+		HeaderKeys:             dkimHeaders,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("dkim: signing: %w", err)
+	}
+	return signed, nil
+}
@@ -2,15 +2,39 @@ package email
 
 import "log"
 
+// DummyMailer logs messages to stdout. See package devmail for capturing and viewing them.
 type DummyMailer struct{}
 
 func (DummyMailer) Send(to string, subject string, body []byte) error {
-	if !AddressValid(to) {
-		return ErrInvalidAddress
+	return DummyMailer{}.SendMessage(&Message{
+		To:      []string{to},
+		Subject: subject,
+		Text:    body,
+	})
+}
+
+func (DummyMailer) SendMessage(msg *Message) error {
+	if err := msg.validate(); err != nil {
+		return err
 	}
 	log.Println("------ dummy mailer ------")
-	log.Printf("to: %s", to)
-	log.Printf("subject: %s", subject)
-	log.Printf("body: %s", body)
+	log.Printf("to: %s", msg.To)
+	if len(msg.Cc) > 0 {
+		log.Printf("cc: %s", msg.Cc)
+	}
+	if len(msg.Bcc) > 0 {
+		log.Printf("bcc: %s", msg.Bcc)
+	}
+	if msg.ReplyTo != "" {
+		log.Printf("reply-to: %s", msg.ReplyTo)
+	}
+	log.Printf("subject: %s", msg.Subject)
+	log.Printf("body: %s", msg.Text)
+	if len(msg.HTML) > 0 {
+		log.Printf("html: %s", msg.HTML)
+	}
+	for _, a := range msg.Attachments {
+		log.Printf("attachment: %s (%s, %d bytes)", a.Filename, a.contentType(), len(a.Data))
+	}
 	return nil
 }
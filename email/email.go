@@ -1,41 +1,108 @@
-// Package email implements email submission.
+// Package email implements email submission, MIME messages and localized email templates.
 package email
 
 import (
 	"bytes"
 	"errors"
-	"fmt"
-	"mime"
 	"net/mail"
 	"strings"
-	"time"
 
 	"github.com/dys2p/eco/id"
+	"golang.org/x/net/idna"
 )
 
 var ErrInvalidAddress = errors.New("invalid address")
 
+var ErrPlainEmailer = errors.New("emailer can send plain text messages to a single recipient only")
+
 type Emailer interface {
 	Send(to string, subject string, body []byte) error
 }
 
-// AddressValid returns true if addr is a well-formed email address, and if it exactly one email address and not a list.
+// MessageSender is an Emailer which can send a Message with Cc, Bcc, HTML, attachments and additional headers. All Emailers of this package implement it.
+type MessageSender interface {
+	Emailer
+	SendMessage(msg *Message) error
+}
+
+// SendMessage sends msg with emailer. If emailer does not implement MessageSender, msg must be a plain text message with a single To recipient and without optional fields, else ErrPlainEmailer is returned.
+func SendMessage(emailer Emailer, msg *Message) error {
+	if sender, ok := emailer.(MessageSender); ok {
+		return sender.SendMessage(msg)
+	}
+	if len(msg.To) != 1 || len(msg.Cc) > 0 || len(msg.Bcc) > 0 || msg.From != "" || msg.ReplyTo != "" || msg.MessageID != "" || len(msg.HTML) > 0 || len(msg.Attachments) > 0 || len(msg.Header) > 0 {
+		return ErrPlainEmailer
+	}
+	return emailer.Send(msg.To[0], msg.Subject, msg.Text)
+}
+
+// AddressValid returns true if addr is a well-formed email address, and if it is exactly one email address and not a list.
+// The display name and the domain may be internationalized, like "Jürgen <jürgen@müller.example>".
 // Use AddressValid to check the email address in your application.
 func AddressValid(addr string) bool {
-	_, err := mail.ParseAddress(addr)
+	_, err := parseAddress(addr)
 	return err == nil
 }
 
+// parseAddress parses a single address and converts an internationalized domain name to its ASCII form (punycode).
+// A non-ASCII local part is kept. It requires SMTPUTF8 (RFC 6531).
+func parseAddress(addr string) (*mail.Address, error) {
+	parsed, err := mail.ParseAddress(addr)
+	if err != nil {
+		return nil, ErrInvalidAddress
+	}
+	at := strings.LastIndex(parsed.Address, "@")
+	if at < 0 {
+		return nil, ErrInvalidAddress
+	}
+	local, domain := parsed.Address[:at], parsed.Address[at+1:]
+	if !strings.HasPrefix(domain, "[") { // domain literal
+		domain, err = idna.Lookup.ToASCII(domain)
+		if err != nil {
+			return nil, ErrInvalidAddress
+		}
+	}
+	parsed.Address = local + "@" + domain
+	return parsed, nil
+}
+
 func getDomain(address string) (string, error) {
-	addr, err := mail.ParseAddress(address)
+	addr, err := parseAddress(address)
+	if err != nil {
+		return "", err
+	}
+	return addr.Address[strings.LastIndex(addr.Address, "@")+1:], nil
+}
+
+// addrSpec returns the bare address of addr, e.g. "test@example.com" for "Test <test@example.com>", for use in the SMTP envelope.
+func addrSpec(addr string) (string, error) {
+	parsed, err := parseAddress(addr)
 	if err != nil {
 		return "", err
 	}
-	if at := strings.LastIndex(addr.Address, "@"); at >= 0 {
-		return addr.Address[at+1:], nil
-	} else {
-		return "", fmt.Errorf("addr contains no @: %s", addr)
+	return parsed.Address, nil
+}
+
+func addrSpecs(addrs []string) ([]string, error) {
+	var specs = make([]string, len(addrs))
+	for i, addr := range addrs {
+		spec, err := addrSpec(addr)
+		if err != nil {
+			return nil, err
+		}
+		specs[i] = spec
+	}
+	return specs, nil
+}
+
+// NewMessageID creates a new Message-ID with the domain of the from address. MakeMessage sets it if Message.MessageID is empty.
+// Set it yourself if you need it before the message is sent, for example to match bounces of queued messages (see package bounce).
+func NewMessageID(from string) (string, error) {
+	domain, err := getDomain(from)
+	if err != nil {
+		return "", err
 	}
+	return newMessageId(domain), nil
 }
 
 // newMessageId creates a new RFC5322 compliant Message-Id with the given domain as "id-right".
@@ -46,21 +113,11 @@ func newMessageId(domain string) string {
 	return (&mail.Address{Address: idLeft + "@" + domain}).String()
 }
 
+// MakeEmail creates a text/plain email. See MakeMessage for HTML and attachments.
 func MakeEmail(from, to, subject string, body []byte) (*bytes.Buffer, error) {
-	fromDomain, err := getDomain(from)
-	if err != nil {
-		return nil, err
-	}
-
-	msg := &bytes.Buffer{}
-	msg.WriteString("MIME-Version: 1.0" + "\r\n")
-	msg.WriteString("Content-Type: text/plain; charset=utf-8" + "\r\n")
-	msg.WriteString("Date: " + time.Now().Format("2 Jan 2006 15:04:05 -0700") + "\r\n")
-	msg.WriteString("Message-ID: " + newMessageId(fromDomain) + "\r\n") //
-	msg.WriteString("From: " + mime.QEncoding.Encode("utf-8", from) + "\r\n")
-	msg.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n")
-	msg.WriteString("To: " + mime.QEncoding.Encode("utf-8", to) + "\r\n")
-	msg.WriteString("\r\n")
-	msg.Write(body)
-	return msg, nil
+	return MakeMessage(from, &Message{
+		To:      []string{to},
+		Subject: subject,
+		Text:    body,
+	})
 }
@@ -1,6 +1,9 @@
 package email
 
-import "testing"
+import (
+	"errors"
+	"testing"
+)
 
 var emailers = []Emailer{
 	DummyMailer{},
@@ -35,3 +38,42 @@ func TestInvalidAddresses(t *testing.T) {
 		}
 	}
 }
+
+func TestAddressValid(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"test@example.com":             true,
+		"Jürgen <j@example.com>":       true,
+		`"Jürgen" <j@müller.example>`:  true,
+		"jürgen@müller.example":        true,
+		"j@xn--mller-kva.example":      true,
+		"test@-example.com":            false, // invalid hostname label
+		"test@exa mple.com":            false,
+		"a@example.com, b@example.com": false,
+	} {
+		if got := AddressValid(addr); got != want {
+			t.Fatalf("%s: got %t, want %t", addr, got, want)
+		}
+	}
+}
+
+type plainMailer struct {
+	sent []string
+}
+
+func (m *plainMailer) Send(to string, subject string, body []byte) error {
+	m.sent = append(m.sent, to+" "+subject+" "+string(body))
+	return nil
+}
+
+func TestSendMessagePlain(t *testing.T) {
+	mailer := &plainMailer{}
+	if err := SendMessage(mailer, &Message{To: []string{"test@example.com"}, Subject: "Subject", Text: []byte("Hello")}); err != nil {
+		t.Fatal(err)
+	}
+	if len(mailer.sent) != 1 || mailer.sent[0] != "test@example.com Subject Hello" {
+		t.Fatalf("got %v", mailer.sent)
+	}
+	if err := SendMessage(mailer, &Message{To: []string{"test@example.com"}, HTML: []byte("<p>Hello</p>")}); !errors.Is(err, ErrPlainEmailer) {
+		t.Fatalf("got %v, want %v", err, ErrPlainEmailer)
+	}
+}
@@ -0,0 +1,393 @@
+package email
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+var ErrInvalidHeader = errors.New("invalid header")
+
+// An Attachment is a file which is attached to a Message. If ContentID is set, the attachment is an inline part of the HTML body, which can refer to it with "cid:" + ContentID, e.g. <img src="cid:logo">.
+type Attachment struct {
+	Filename    string
+	ContentType string // without parameters, default: detected from the filename extension, or application/octet-stream
+	ContentID   string // without angle brackets and whitespace
+	Data        []byte
+}
+
+func (a Attachment) contentType() string {
+	if a.ContentType != "" {
+		return a.ContentType
+	}
+	if t := mime.TypeByExtension(filepath.Ext(a.Filename)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// A Message is an email with text and HTML alternatives, attachments and additional headers.
+type Message struct {
+	From        string // default: From of the Emailer
+	To          []string
+	Cc          []string
+	Bcc         []string // not written to the header
+	ReplyTo     string
+	Subject     string
+	MessageID   string // with angle brackets, created by MakeMessage if empty
+	Text        []byte
+	HTML        []byte
+	Attachments []Attachment
+	Header      map[string]string // additional headers like "List-Unsubscribe"
+}
+
+// Recipients returns the envelope recipients, which are To, Cc and Bcc.
+func (msg *Message) Recipients() []string {
+	var recipients []string
+	recipients = append(recipients, msg.To...)
+	recipients = append(recipients, msg.Cc...)
+	recipients = append(recipients, msg.Bcc...)
+	return recipients
+}
+
+// reservedHeaders are set by MakeMessage from the fields of Message, so they must not be set in Message.Header. Content-* headers are reserved as well.
+var reservedHeaders = []string{"Bcc", "Cc", "Date", "From", "Message-Id", "Mime-Version", "Reply-To", "Subject", "To"}
+
+// validate checks the addresses and headers of msg.
+func (msg *Message) validate() error {
+	if len(msg.To) == 0 {
+		return ErrInvalidAddress
+	}
+	for _, addr := range msg.Recipients() {
+		if !AddressValid(addr) {
+			return ErrInvalidAddress
+		}
+	}
+	if msg.ReplyTo != "" && !AddressValid(msg.ReplyTo) {
+		return ErrInvalidAddress
+	}
+	for key, value := range msg.Header {
+		if key == "" || strings.ContainsAny(key, ": \r\n") || strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("%w: %q", ErrInvalidHeader, key)
+		}
+		if canonical := textproto.CanonicalMIMEHeaderKey(key); slices.Contains(reservedHeaders, canonical) || strings.HasPrefix(canonical, "Content-") {
+			return fmt.Errorf("%w: %q is reserved", ErrInvalidHeader, key)
+		}
+	}
+	for _, a := range msg.Attachments {
+		if a.ContentType != "" && mime.FormatMediaType(a.ContentType, nil) == "" {
+			return fmt.Errorf("%w: attachment content type %q", ErrInvalidHeader, a.ContentType)
+		}
+		if strings.ContainsAny(a.ContentID, "<>\" \t\r\n") {
+			return fmt.Errorf("%w: attachment content id %q", ErrInvalidHeader, a.ContentID)
+		}
+	}
+	return nil
+}
+
+// MakeMessage creates a MIME message. The structure is:
+//
+//	multipart/mixed (if there are attachments)
+//	  multipart/related (if there are inline attachments)
+//	    multipart/alternative (if there is text and HTML)
+//	      text/plain
+//	      text/html
+//	    inline attachments
+//	  attachments
+//
+// Parts which are not required are omitted. If msg.MessageID is empty, it is set to a new Message-ID.
+func MakeMessage(from string, msg *Message) (*bytes.Buffer, error) {
+	if msg.From != "" {
+		from = msg.From
+	}
+	if err := msg.validate(); err != nil {
+		return nil, err
+	}
+	fromDomain, err := getDomain(from)
+	if err != nil {
+		return nil, err
+	}
+	if msg.MessageID == "" {
+		msg.MessageID = newMessageId(fromDomain)
+	}
+
+	var header = make(textproto.MIMEHeader)
+	header.Set("MIME-Version", "1.0")
+	header.Set("Date", time.Now().Format("2 Jan 2006 15:04:05 -0700"))
+	header.Set("Message-ID", msg.MessageID)
+	header.Set("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	for key, addrs := range map[string][]string{
+		"From":     {from},
+		"To":       msg.To,
+		"Cc":       msg.Cc,
+		"Reply-To": {msg.ReplyTo},
+	} {
+		if len(addrs) == 0 || addrs[0] == "" {
+			continue
+		}
+		encoded, err := encodeAddressList(addrs)
+		if err != nil {
+			return nil, err
+		}
+		header.Set(key, encoded)
+	}
+	for key, value := range msg.Header {
+		header.Set(key, mime.QEncoding.Encode("utf-8", value))
+	}
+
+	var body = &bytes.Buffer{}
+	if err := msg.writeBody(header, body); err != nil {
+		return nil, err
+	}
+
+	var buf = &bytes.Buffer{}
+	writeHeader(buf, header)
+	buf.WriteString("\r\n")
+	buf.Write(body.Bytes())
+	return buf, nil
+}
+
+// headerOrder is the order of the well-known headers. Other headers follow in alphabetical order.
+var headerOrder = []string{"MIME-Version", "Content-Type", "Content-Transfer-Encoding", "Date", "Message-ID", "From", "Reply-To", "Subject", "To", "Cc"}
+
+func writeHeader(w io.Writer, header textproto.MIMEHeader) {
+	var written = make(map[string]bool)
+	for _, key := range headerOrder {
+		canonical := textproto.CanonicalMIMEHeaderKey(key) // "Message-Id"
+		for _, value := range header[canonical] {
+			fmt.Fprintf(w, "%s\r\n", foldHeader(key+": "+value)) // "Message-ID"
+		}
+		written[canonical] = true
+	}
+	var keys []string
+	for key := range header {
+		if !written[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range header[key] {
+			fmt.Fprintf(w, "%s\r\n", foldHeader(key+": "+value))
+		}
+	}
+}
+
+// foldHeader folds a header line at whitespace, so that its lines do not exceed 78 characters if possible (RFC 5322 section 2.2.3).
+// A line without whitespace is folded at the next whitespace, as it must not be split.
+func foldHeader(line string) string {
+	const limit = 78
+	var sb strings.Builder
+	start := strings.IndexByte(line, ' ') + 1 // don't fold between the name and the value
+	for len(line) > limit {
+		i := strings.LastIndexByte(line[:limit+1], ' ')
+		if i < start {
+			from := max(start, limit)
+			j := strings.IndexByte(line[from:], ' ')
+			if j < 0 {
+				break
+			}
+			i = from + j
+		}
+		sb.WriteString(line[:i])
+		sb.WriteString("\r\n")
+		line = line[i:] // continues with the whitespace
+		start = 1
+	}
+	sb.WriteString(line)
+	return sb.String()
+}
+
+// writeBody writes the body to w and sets the Content-Type (and Content-Transfer-Encoding, if the body is not multipart) of the message header.
+func (msg *Message) writeBody(header textproto.MIMEHeader, w io.Writer) error {
+	var inline, attached []Attachment
+	for _, a := range msg.Attachments {
+		if a.ContentID != "" && len(msg.HTML) > 0 {
+			inline = append(inline, a)
+		} else {
+			attached = append(attached, a)
+		}
+	}
+
+	// build the tree from the inside out
+	var content = msg.alternativePart()
+	if len(inline) > 0 {
+		related := &part{contentType: "multipart/related", children: []*part{content}}
+		for _, a := range inline {
+			related.children = append(related.children, attachmentPart(a, "inline"))
+		}
+		content = related
+	}
+	if len(attached) > 0 {
+		mixed := &part{contentType: "multipart/mixed", children: []*part{content}}
+		for _, a := range attached {
+			mixed.children = append(mixed.children, attachmentPart(a, "attachment"))
+		}
+		content = mixed
+	}
+
+	for key, values := range content.header() {
+		header[key] = values
+	}
+	return content.writeBody(w)
+}
+
+func (msg *Message) alternativePart() *part {
+	text := textPart("text/plain", msg.Text)
+	if len(msg.HTML) == 0 {
+		return text
+	}
+	html := textPart("text/html", msg.HTML)
+	if len(msg.Text) == 0 {
+		return html
+	}
+	return &part{contentType: "multipart/alternative", children: []*part{text, html}}
+}
+
+// part is a node of the MIME tree. It is either multipart (children) or a leaf (data).
+type part struct {
+	contentType string
+	params      map[string]string
+	extra       textproto.MIMEHeader
+	encoding    string // "quoted-printable" or "base64"
+	data        []byte
+	children    []*part
+}
+
+func textPart(contentType string, data []byte) *part {
+	return &part{
+		contentType: contentType,
+		params:      map[string]string{"charset": "utf-8"},
+		encoding:    "quoted-printable",
+		data:        data,
+	}
+}
+
+func attachmentPart(a Attachment, disposition string) *part {
+	p := &part{
+		contentType: a.contentType(),
+		extra:       make(textproto.MIMEHeader),
+		encoding:    "base64",
+		data:        a.Data,
+	}
+	if a.Filename != "" {
+		p.params = map[string]string{"name": a.Filename}
+		p.extra.Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": a.Filename}))
+	} else {
+		p.extra.Set("Content-Disposition", disposition)
+	}
+	if a.ContentID != "" {
+		p.extra.Set("Content-ID", "<"+a.ContentID+">")
+	}
+	return p
+}
+
+func (p *part) header() textproto.MIMEHeader {
+	var header = make(textproto.MIMEHeader)
+	for key, values := range p.extra {
+		header[key] = values
+	}
+	params := p.params
+	if len(p.children) > 0 {
+		params = map[string]string{"boundary": p.boundary()}
+	}
+	header.Set("Content-Type", mime.FormatMediaType(p.contentType, params))
+	if p.encoding != "" {
+		header.Set("Content-Transfer-Encoding", p.encoding)
+	}
+	return header
+}
+
+func (p *part) boundary() string {
+	if p.params == nil {
+		p.params = make(map[string]string)
+	}
+	if p.params["boundary"] == "" {
+		p.params["boundary"] = multipart.NewWriter(io.Discard).Boundary()
+	}
+	return p.params["boundary"]
+}
+
+func (p *part) writeBody(w io.Writer) error {
+	if len(p.children) == 0 {
+		return p.writeData(w)
+	}
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(p.boundary()); err != nil {
+		return err
+	}
+	for _, child := range p.children {
+		cw, err := mw.CreatePart(child.header())
+		if err != nil {
+			return err
+		}
+		if err := child.writeBody(cw); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+func (p *part) writeData(w io.Writer) error {
+	switch p.encoding {
+	case "base64":
+		encoded := base64.StdEncoding.EncodeToString(p.data)
+		for len(encoded) > 76 {
+			if _, err := io.WriteString(w, encoded[:76]+"\r\n"); err != nil {
+				return err
+			}
+			encoded = encoded[76:]
+		}
+		_, err := io.WriteString(w, encoded+"\r\n")
+		return err
+	default:
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write(normalizeNewlines(p.data)); err != nil {
+			return err
+		}
+		return qp.Close()
+	}
+}
+
+// normalizeNewlines converts LF and CR to CRLF, as required by RFC 5322.
+func normalizeNewlines(data []byte) []byte {
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	data = bytes.ReplaceAll(data, []byte("\r"), []byte("\n"))
+	return bytes.ReplaceAll(data, []byte("\n"), []byte("\r\n"))
+}
+
+// encodeAddress returns addr with an RFC 2047 encoded display name and an ASCII domain name. A non-ASCII local part is kept, see parseAddress.
+func encodeAddress(addr string) (string, error) {
+	parsed, err := parseAddress(addr)
+	if err != nil {
+		return "", err
+	}
+	encoded := parsed.String()
+	if parsed.Name == "" {
+		encoded = strings.TrimSuffix(strings.TrimPrefix(encoded, "<"), ">") // bare addr-spec
+	}
+	return encoded, nil
+}
+
+func encodeAddressList(addrs []string) (string, error) {
+	var encoded = make([]string, len(addrs))
+	for i, addr := range addrs {
+		var err error
+		encoded[i], err = encodeAddress(addr)
+		if err != nil {
+			return "", err
+		}
+	}
+	return strings.Join(encoded, ", "), nil
+}
@@ -0,0 +1,239 @@
+package email
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+// walk returns the content types of the MIME tree in depth-first order, and the decoded leaf contents.
+func walk(t *testing.T, contentType string, body io.Reader) ([]string, map[string]string) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types = []string{mediaType}
+	var contents = make(map[string]string)
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		data, err := io.ReadAll(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents[mediaType] = string(data)
+		return types, contents
+	}
+	mr := multipart.NewReader(body, params["boundary"])
+	for {
+		p, err := mr.NextPart() // decodes quoted-printable, but not base64
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		childTypes, childContents := walk(t, p.Header.Get("Content-Type"), p)
+		types = append(types, childTypes...)
+		for k, v := range childContents {
+			contents[k] = v
+		}
+	}
+	return types, contents
+}
+
+func TestMakeMessage(t *testing.T) {
+	tests := []struct {
+		msg   *Message
+		types []string
+	}{
+		{
+			&Message{To: []string{"to@example.com"}, Text: []byte("Hello")},
+			[]string{"text/plain"},
+		},
+		{
+			&Message{To: []string{"to@example.com"}, Text: []byte("Hello"), HTML: []byte("<p>Hello</p>")},
+			[]string{"multipart/alternative", "text/plain", "text/html"},
+		},
+		{
+			&Message{
+				To:   []string{"to@example.com"},
+				Text: []byte("Hello"),
+				HTML: []byte(`<p>Hello</p><img src="cid:logo">`),
+				Attachments: []Attachment{
+					{Filename: "logo.png", ContentID: "logo", Data: []byte("png")},
+					{Filename: "invoice.pdf", Data: []byte("%PDF-1.4")},
+				},
+			},
+			[]string{"multipart/mixed", "multipart/related", "multipart/alternative", "text/plain", "text/html", "image/png", "application/pdf"},
+		},
+		{
+			&Message{
+				To:          []string{"to@example.com"},
+				Text:        []byte("Hello"),
+				Attachments: []Attachment{{Filename: "invoice.pdf", Data: []byte("%PDF-1.4")}},
+			},
+			[]string{"multipart/mixed", "text/plain", "application/pdf"},
+		},
+	}
+
+	for _, test := range tests {
+		buf, err := MakeMessage("from@example.com", test.msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := mail.ReadMessage(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed.Header.Get("Message-ID") != test.msg.MessageID {
+			t.Fatalf("got Message-ID %s, want %s", parsed.Header.Get("Message-ID"), test.msg.MessageID)
+		}
+		types, contents := walk(t, parsed.Header.Get("Content-Type"), parsed.Body)
+		if strings.Join(types, " ") != strings.Join(test.types, " ") {
+			t.Fatalf("got %v, want %v", types, test.types)
+		}
+		if contents["text/plain"] != "Hello" {
+			t.Fatalf("got text %q", contents["text/plain"])
+		}
+	}
+}
+
+func TestMakeMessageHeaders(t *testing.T) {
+	msg := &Message{
+		To:      []string{"to@example.com"},
+		Cc:      []string{"cc@example.com"},
+		Bcc:     []string{"bcc@example.com"},
+		ReplyTo: "reply@example.com",
+		Subject: "Ihre Bestellung",
+		Text:    []byte("Hello"),
+		Header:  map[string]string{"List-Unsubscribe": "<mailto:unsubscribe@example.com>"},
+	}
+	buf, err := MakeMessage("from@example.com", msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(buf.Bytes(), []byte("bcc@example.com")) {
+		t.Fatal("Bcc is in the message")
+	}
+	parsed, err := mail.ReadMessage(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{
+		"Cc":               "cc@example.com",
+		"Reply-To":         "reply@example.com",
+		"List-Unsubscribe": "<mailto:unsubscribe@example.com>",
+	} {
+		if got := parsed.Header.Get(key); got != want {
+			t.Fatalf("%s: got %q, want %q", key, got, want)
+		}
+	}
+	if got := msg.Recipients(); len(got) != 3 {
+		t.Fatalf("got recipients %v", got)
+	}
+
+	msg.Header = map[string]string{"X-Evil": "a\r\nBcc: victim@example.com"}
+	if _, err := MakeMessage("from@example.com", msg); err == nil {
+		t.Fatal("header injection: got no error")
+	}
+
+	msg.Header = nil
+	for _, a := range []Attachment{
+		{Filename: "logo.png", ContentID: "logo\r\nX-Evil: 1", Data: []byte("png")},
+		{Filename: "logo.png", ContentID: "<logo>", Data: []byte("png")},
+		{Filename: "a.txt", ContentType: "text/plain\r\n\r\n--boundary", Data: []byte("a")},
+		{Filename: "a.txt", ContentType: "text/plain; charset=utf-8", Data: []byte("a")},
+	} {
+		msg.Attachments = []Attachment{a}
+		if _, err := MakeMessage("from@example.com", msg); !errors.Is(err, ErrInvalidHeader) {
+			t.Fatalf("attachment %q %q: got %v", a.ContentType, a.ContentID, err)
+		}
+	}
+	msg.Attachments = nil
+
+	for _, key := range []string{"From", "to", "Message-ID", "MIME-Version", "content-type", "Content-Transfer-Encoding"} {
+		msg.Header = map[string]string{key: "x"}
+		if _, err := MakeMessage("from@example.com", msg); !errors.Is(err, ErrInvalidHeader) {
+			t.Fatalf("reserved header %s: got %v", key, err)
+		}
+	}
+}
+
+func TestEncodeAddress(t *testing.T) {
+	for addr, want := range map[string]string{
+		"test@example.com":                           "test@example.com",
+		"Test <test@example.com>":                    `"Test" <test@example.com>`,
+		`"Jürgen" <j@example.com>`:                   "=?utf-8?q?J=C3=BCrgen?= <j@example.com>",
+		"Jürgen <j@example.com>":                     "=?utf-8?q?J=C3=BCrgen?= <j@example.com>",
+		"=?utf-8?q?J=C3=BCrgen?= <j@Müller.example>": "=?utf-8?q?J=C3=BCrgen?= <j@xn--mller-kva.example>",
+		`"Doe, John" <john@example.com>`:             `"Doe, John" <john@example.com>`,
+		"jürgen@müller.example":                      "jürgen@xn--mller-kva.example", // requires SMTPUTF8
+	} {
+		got, err := encodeAddress(addr)
+		if err != nil {
+			t.Fatalf("%s: %v", addr, err)
+		}
+		if got != want {
+			t.Fatalf("%s: got %q, want %q", addr, got, want)
+		}
+	}
+}
+
+func TestFoldHeader(t *testing.T) {
+	long := strings.Repeat("x", 100)
+	for line, want := range map[string]string{
+		"Subject: short": "Subject: short",
+		"To: " + strings.Repeat("a@example.com, ", 6) + "b@example.com": "To: a@example.com, a@example.com, a@example.com, a@example.com, a@example.com,\r\n a@example.com, b@example.com",
+		"Subject: " + long + " end":                                     "Subject: " + long + "\r\n end",
+		"Message-ID: <" + long + ">":                                    "Message-ID: <" + long + ">",
+	} {
+		if got := foldHeader(line); got != want {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestMakeMessageInternational(t *testing.T) {
+	buf, err := MakeMessage("Bücherei <shop@bücher.example>", &Message{
+		To:      []string{"Jürgen Müller <j@example.com>"},
+		Subject: strings.Repeat("Ihre Bestellung bei der Bücherei ", 4),
+		Text:    []byte("Hello"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(buf.String(), "\r\n") {
+		if len(line) > 78 && len(strings.Fields(line)) > 2 { // header name plus one encoded-word can't be folded
+			t.Fatalf("line too long: %q", line)
+		}
+	}
+	parsed, err := mail.ReadMessage(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	from, err := parsed.Header.AddressList("From")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from[0].Name != "Bücherei" || from[0].Address != "shop@xn--bcher-kva.example" {
+		t.Fatalf("got from %v", from[0])
+	}
+	to, err := parsed.Header.AddressList("To")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if to[0].Name != "Jürgen Müller" {
+		t.Fatalf("got to %v", to[0])
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject != strings.Repeat("Ihre Bestellung bei der Bücherei ", 4) {
+		t.Fatalf("got subject %q", subject)
+	}
+}
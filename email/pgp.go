@@ -0,0 +1,327 @@
+package email
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+)
+
+// A KeyLookup finds the OpenPGP public keys of an email address. It returns an empty list if there is no key.
+type KeyLookup interface {
+	Lookup(ctx context.Context, address string) (openpgp.EntityList, error)
+}
+
+// KeyLookups tries each KeyLookup in order and returns the first keys found.
+type KeyLookups []KeyLookup
+
+func (lookups KeyLookups) Lookup(ctx context.Context, address string) (openpgp.EntityList, error) {
+	for _, lookup := range lookups {
+		keys, err := lookup.Lookup(ctx, address)
+		if err != nil {
+			return nil, err
+		}
+		if len(keys) > 0 {
+			return keys, nil
+		}
+	}
+	return nil, nil
+}
+
+// Keyring is a KeyLookup which searches a list of keys, for example from a local keyring file.
+type Keyring openpgp.EntityList
+
+// ReadKeyring reads an armored or binary keyring file, for example the output of "gpg --export --armor".
+func ReadKeyring(path string) (Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseKeyring(data)
+}
+
+func parseKeyring(data []byte) (Keyring, error) {
+	var keys openpgp.EntityList
+	var err error
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("-----BEGIN")) {
+		keys, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+	} else {
+		keys, err = openpgp.ReadKeyRing(bytes.NewReader(data))
+	}
+	return Keyring(keys), err
+}
+
+// Lookup returns the keys which have a user ID with the given email address. Revoked and expired keys are skipped.
+func (keyring Keyring) Lookup(ctx context.Context, address string) (openpgp.EntityList, error) {
+	address, err := addrSpec(address)
+	if err != nil {
+		return nil, err
+	}
+	var result openpgp.EntityList
+	for _, entity := range keyring {
+		if _, ok := entity.EncryptionKey(time.Now()); !ok {
+			continue
+		}
+		for _, identity := range entity.Identities {
+			if identity.UserId != nil && strings.EqualFold(identity.UserId.Email, address) {
+				result = append(result, entity)
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+// WKD is a KeyLookup which queries the Web Key Directory of the recipient's domain. It makes an HTTP request to that domain, which reveals the recipient to a third party, so use it only if your privacy policy allows it.
+type WKD struct {
+	Client *http.Client // default: client with a timeout of 10 seconds
+}
+
+// Lookup tries the advanced method, then the direct method. Not found and an invalid key are not errors.
+func (wkd WKD) Lookup(ctx context.Context, address string) (openpgp.EntityList, error) {
+	address, err := addrSpec(address)
+	if err != nil {
+		return nil, err
+	}
+	client := wkd.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	for _, u := range wkdURLs(address) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			continue // e.g. openpgpkey subdomain does not exist
+		}
+		data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		resp.Body.Close()
+		if err != nil || resp.StatusCode != http.StatusOK {
+			continue
+		}
+		keyring, err := parseKeyring(data)
+		if err != nil {
+			log.Printf("\033[31m"+"wkd: parsing key from %s: %v"+"\033[0m", u, err)
+			return nil, nil // like no key
+		}
+		// the key must contain the address
+		return keyring.Lookup(ctx, address)
+	}
+	return nil, nil
+}
+
+// wkdURLs returns the URLs of the advanced and the direct method (draft-koch-openpgp-webkey-service).
+func wkdURLs(address string) []string {
+	at := strings.LastIndex(address, "@")
+	local, domain := address[:at], strings.ToLower(address[at+1:])
+	hash := sha1.Sum([]byte(strings.ToLower(local)))
+	hu := zbase32(hash[:])
+	l := url.QueryEscape(local)
+	return []string{
+		fmt.Sprintf("https://openpgpkey.%s/.well-known/openpgpkey/%s/hu/%s?l=%s", domain, domain, hu, l),
+		fmt.Sprintf("https://%s/.well-known/openpgpkey/hu/%s?l=%s", domain, hu, l),
+	}
+}
+
+// zbase32 encodes data with the human-oriented base-32 encoding.
+func zbase32(data []byte) string {
+	const alphabet = "ybndrfg8ejkmcpqxot1uwisza345h769"
+	var sb strings.Builder
+	var buffer, bits uint
+	for _, b := range data {
+		buffer = buffer<<8 | uint(b)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			sb.WriteByte(alphabet[(buffer>>bits)&31])
+		}
+	}
+	if bits > 0 {
+		sb.WriteByte(alphabet[(buffer<<(5-bits))&31])
+	}
+	return sb.String()
+}
+
+// PGP encrypts messages with PGP/MIME (RFC 3156) if all recipients have a key. Otherwise messages are sent in plaintext.
+type PGP struct {
+	Keys    KeyLookup
+	SignKey *openpgp.Entity // optional, must contain a decrypted private key
+}
+
+// Encrypt returns mail as a multipart/encrypted message, or nil if a recipient has no key. The recipients are the envelope recipients.
+// Don't include Bcc recipients, because the key IDs of all recipients are visible to each recipient. Sendmail and SMTP send a separate copy to each Bcc recipient.
+//
+// The Content-* headers and the body of mail are encrypted. The other headers, including the Subject, remain readable.
+func (pgp *PGP) Encrypt(mail *bytes.Buffer, recipients []string) (*bytes.Buffer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var keys []*openpgp.Entity
+	for _, recipient := range recipients {
+		recipientKeys, err := pgp.Keys.Lookup(ctx, recipient)
+		if err != nil {
+			return nil, fmt.Errorf("looking up key of %s: %w", recipient, err)
+		}
+		if len(recipientKeys) == 0 {
+			return nil, nil // fallback to plaintext
+		}
+		keys = append(keys, recipientKeys[0])
+	}
+
+	// split the message into outer headers and inner MIME entity
+	reader := textproto.NewReader(bufio.NewReader(bytes.NewReader(mail.Bytes())))
+	header, err := reader.ReadMIMEHeader()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	body, err := io.ReadAll(reader.R)
+	if err != nil {
+		return nil, err
+	}
+	var inner = &bytes.Buffer{}
+	var innerHeader = make(textproto.MIMEHeader)
+	for key, values := range header {
+		if strings.HasPrefix(key, "Content-") {
+			innerHeader[key] = values
+			delete(header, key)
+		}
+	}
+	writeHeader(inner, innerHeader)
+	inner.WriteString("\r\n")
+	inner.Write(body)
+
+	// encrypt
+	var ciphertext = &bytes.Buffer{}
+	armored, err := armor.Encode(ciphertext, "PGP MESSAGE", nil)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := openpgp.Encrypt(armored, keys, pgp.SignKey, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("encrypting: %w", err)
+	}
+	if _, err := plaintext.Write(inner.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := plaintext.Close(); err != nil {
+		return nil, err
+	}
+	if err := armored.Close(); err != nil {
+		return nil, err
+	}
+
+	// build multipart/encrypted
+	var encrypted = &bytes.Buffer{}
+	mw := multipart.NewWriter(encrypted)
+	header.Set("Content-Type", `multipart/encrypted; protocol="application/pgp-encrypted"; boundary="`+mw.Boundary()+`"`)
+	control, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":        {"application/pgp-encrypted"},
+		"Content-Description": {"PGP/MIME version identification"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	io.WriteString(control, "Version: 1\r\n")
+	data, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":        {`application/octet-stream; name="encrypted.asc"`},
+		"Content-Description": {"OpenPGP encrypted message"},
+		"Content-Disposition": {`inline; filename="encrypted.asc"`},
+	})
+	if err != nil {
+		return nil, err
+	}
+	data.Write(normalizeNewlines(ciphertext.Bytes()))
+	io.WriteString(data, "\r\n")
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	var result = &bytes.Buffer{}
+	writeHeader(result, header)
+	result.WriteString("\r\n")
+	result.Write(encrypted.Bytes())
+	return result, nil
+}
+
+// envelope is a message and its SMTP envelope addresses.
+type envelope struct {
+	from string
+	to   []string
+	mail *bytes.Buffer
+}
+
+// makeEnvelopes builds msg and applies pgp and dkim, which may be nil. Usually it returns one envelope for all recipients.
+// If pgp is set and msg has Bcc recipients, each Bcc recipient gets a separate copy, because an encrypted message reveals the key IDs of all its recipients.
+func makeEnvelopes(from string, msg *Message, pgp *PGP, dkim *DKIM) ([]envelope, error) {
+	mail, err := MakeMessage(from, msg)
+	if err != nil {
+		return nil, err
+	}
+	if msg.From != "" {
+		from = msg.From
+	}
+	from, err = addrSpec(from)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups = [][]string{msg.Recipients()}
+	if pgp != nil && len(msg.Bcc) > 0 {
+		groups = [][]string{append(slices.Clone(msg.To), msg.Cc...)}
+		for _, bcc := range msg.Bcc {
+			groups = append(groups, []string{bcc})
+		}
+	}
+
+	var envelopes []envelope
+	for _, group := range groups {
+		to, err := addrSpecs(group)
+		if err != nil {
+			return nil, err
+		}
+		data, err := pgp.encryptIfPossible(bytes.NewBuffer(mail.Bytes()), group) // new buffer for each group, so mail is not consumed
+		if err != nil {
+			return nil, err
+		}
+		if dkim != nil {
+			data, err = dkim.Sign(data)
+			if err != nil {
+				return nil, err
+			}
+		}
+		envelopes = append(envelopes, envelope{from: from, to: to, mail: data})
+	}
+	return envelopes, nil
+}
+
+// encryptIfPossible applies pgp to mail, if pgp is not nil and all recipients have a key.
+func (pgp *PGP) encryptIfPossible(mail *bytes.Buffer, recipients []string) (*bytes.Buffer, error) {
+	if pgp == nil {
+		return mail, nil
+	}
+	encrypted, err := pgp.Encrypt(mail, recipients)
+	if err != nil {
+		return nil, err
+	}
+	if encrypted == nil {
+		return mail, nil
+	}
+	return encrypted, nil
+}
@@ -0,0 +1,253 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+)
+
+func TestWKDURLs(t *testing.T) {
+	// example from draft-koch-openpgp-webkey-service
+	got := wkdURLs("Joe.Doe@Example.ORG")
+	want := []string{
+		"https://openpgpkey.example.org/.well-known/openpgpkey/example.org/hu/iy9q119eutrkn8s1mk4r39qejnbu3n5q?l=Joe.Doe",
+		"https://example.org/.well-known/openpgpkey/hu/iy9q119eutrkn8s1mk4r39qejnbu3n5q?l=Joe.Doe",
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func newTestEntity(t *testing.T, name, email string) *openpgp.Entity {
+	entity, err := openpgp.NewEntity(name, "", email, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entity
+}
+
+// publicKey returns the binary public key of entity.
+func publicKey(t *testing.T, entity *openpgp.Entity) []byte {
+	var buf = &bytes.Buffer{}
+	if err := entity.Serialize(buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestKeyLookup(t *testing.T) {
+	alice := newTestEntity(t, "Alice", "alice@example.com")
+	bob := newTestEntity(t, "Bob", "bob@example.net")
+
+	keyring, err := parseKeyring(publicKey(t, alice))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// WKD server for example.net, direct method only
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host == "example.net" && r.URL.Path == "/.well-known/openpgpkey/hu/"+wkdHash("bob") {
+			w.Write(publicKey(t, bob))
+			return
+		}
+		if r.Host == "example.net" && r.URL.Path == "/.well-known/openpgpkey/hu/"+wkdHash("dave") {
+			w.Write([]byte("<html>not a key</html>"))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+	wkd := WKD{Client: &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		r = r.Clone(r.Context())
+		r.Host = r.URL.Host
+		r.URL.Scheme = "http"
+		r.URL.Host = strings.TrimPrefix(server.URL, "http://")
+		return http.DefaultTransport.RoundTrip(r)
+	})}}
+
+	lookups := KeyLookups{keyring, wkd}
+	for address, want := range map[string]*openpgp.Entity{
+		"Alice <ALICE@example.com>": alice,
+		"bob@example.net":           bob,
+		"carol@example.net":         nil,
+		"dave@example.net":          nil, // invalid key
+	} {
+		keys, err := lookups.Lookup(context.Background(), address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want == nil {
+			if len(keys) != 0 {
+				t.Fatalf("%s: got %d keys, want none", address, len(keys))
+			}
+			continue
+		}
+		if len(keys) != 1 || keys[0].PrimaryKey.KeyId != want.PrimaryKey.KeyId {
+			t.Fatalf("%s: got %v", address, keys)
+		}
+	}
+}
+
+func wkdHash(local string) string {
+	u := wkdURLs(local + "@example.org")[1]
+	u = u[strings.LastIndex(u, "/")+1:]
+	hash, _, _ := strings.Cut(u, "?")
+	return hash
+}
+
+func TestPGP(t *testing.T) {
+	alice := newTestEntity(t, "Alice", "alice@example.com")
+	shop := newTestEntity(t, "Shop", "shop@example.com")
+	pgp := &PGP{Keys: Keyring{alice}, SignKey: shop}
+
+	msg, err := MakeMessage("shop@example.com", &Message{
+		To:      []string{"alice@example.com"},
+		Subject: "Your order",
+		Text:    []byte("Secret"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext := msg.String()
+
+	// fallback if one recipient has no key
+	got, err := pgp.encryptIfPossible(bytes.NewBufferString(plaintext), []string{"alice@example.com", "bob@example.net"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != plaintext {
+		t.Fatal("message without key has been changed")
+	}
+
+	encrypted, err := pgp.Encrypt(bytes.NewBufferString(plaintext), []string{"alice@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(encrypted.String(), "Secret") {
+		t.Fatal("encrypted message contains plaintext")
+	}
+
+	// parse RFC 3156 structure
+	parsed, err := mail.ReadMessage(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Header.Get("Subject") != "Your order" {
+		t.Fatalf("got subject %q", parsed.Header.Get("Subject"))
+	}
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mediaType != "multipart/encrypted" || params["protocol"] != "application/pgp-encrypted" {
+		t.Fatalf("got %s %v", mediaType, params)
+	}
+	mr := multipart.NewReader(parsed.Body, params["boundary"])
+	control, err := mr.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := io.ReadAll(control); !strings.Contains(string(data), "Version: 1") {
+		t.Fatalf("got control part %q", data)
+	}
+	part, err := mr.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := armor.Decode(part)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// decrypt and verify signature
+	md, err := openpgp.ReadMessage(block.Body, openpgp.EntityList{alice, shop}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err := io.ReadAll(md.UnverifiedBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if md.SignatureError != nil || md.SignedBy == nil || md.SignedBy.PublicKey.KeyId != shop.PrimaryKey.KeyId {
+		t.Fatalf("signature: %v", md.SignatureError)
+	}
+	inner, err := mail.ReadMessage(bytes.NewReader(decrypted))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(inner.Header.Get("Content-Type"), "text/plain") {
+		t.Fatalf("got inner content type %q", inner.Header.Get("Content-Type"))
+	}
+	if body, _ := io.ReadAll(inner.Body); string(body) != "Secret" {
+		t.Fatalf("got inner body %q", body)
+	}
+}
+
+func TestPGPBcc(t *testing.T) {
+	alice := newTestEntity(t, "Alice", "alice@example.com")
+	bob := newTestEntity(t, "Bob", "bob@example.com")
+	pgp := &PGP{Keys: Keyring{alice, bob}}
+
+	envelopes, err := makeEnvelopes("shop@example.com", &Message{
+		To:      []string{"alice@example.com"},
+		Bcc:     []string{"bob@example.com"},
+		Subject: "Your order",
+		Text:    []byte("Secret"),
+	}, pgp, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(envelopes) != 2 || strings.Join(envelopes[0].to, " ") != "alice@example.com" || strings.Join(envelopes[1].to, " ") != "bob@example.com" {
+		t.Fatalf("got %+v", envelopes)
+	}
+
+	// each copy is encrypted to its recipients only
+	for i, test := range []struct {
+		reader *openpgp.Entity
+		ok     bool
+	}{
+		{alice, true},
+		{bob, false},
+		{alice, false},
+		{bob, true},
+	} {
+		parsed, err := mail.ReadMessage(bytes.NewReader(envelopes[i/2].mail.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		mr := multipart.NewReader(parsed.Body, params["boundary"])
+		mr.NextPart()
+		part, err := mr.NextPart()
+		if err != nil {
+			t.Fatal(err)
+		}
+		block, err := armor.Decode(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = openpgp.ReadMessage(block.Body, openpgp.EntityList{test.reader}, nil, nil)
+		if (err == nil) != test.ok {
+			t.Fatalf("test %d: got error %v, want ok %t", i, err, test.ok)
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package email
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/emersion/go-smtp"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const (
+	StatePending = "pending"
+	StateDead    = "dead" // delivery failed MaxAttempts times or permanently, see Retry
+)
+
+// Queue is an Emailer which stores messages in an SQLite database before it returns. RunDaemon delivers them through another Emailer, with exponential backoff on failure.
+// Delivered messages are removed from the database.
+//
+// Delivery is at least once per recipient: if the Emailer sends a message in several envelopes (like Bcc copies of encrypted messages) and fails after some of them, the whole message is retried, so some recipients may get it twice.
+type Queue struct {
+	Emailer     Emailer       // delivers the messages, see SendMessage
+	From        string        // optional sender for the Message-ID if the message has no From address, usually the From address of Emailer, see SendMessage
+	MaxAttempts int           // default: 10
+	Backoff     time.Duration // delay after the first failure, doubled after each further failure up to one day, default: one minute
+
+	now  func() time.Time // for testing
+	wake chan struct{}
+
+	sqldb    *sql.DB
+	insert   *sql.Stmt
+	due      *sql.Stmt
+	remove   *sql.Stmt
+	fail     *sql.Stmt
+	list     *sql.Stmt
+	retry    *sql.Stmt
+	countDue *sql.Stmt
+}
+
+// QueuedEmail is a message in the Queue.
+type QueuedEmail struct {
+	ID          int64
+	Message     *Message // nil if the stored message can't be unmarshaled
+	State       string
+	Attempts    int
+	NextAttempt time.Time
+	LastError   string
+	Created     time.Time
+}
+
+func OpenQueue(fpath string, emailer Emailer) (*Queue, error) {
+	sqldb, err := sql.Open("sqlite3", fpath+"?_busy_timeout=10000&_journal=WAL&_sync=NORMAL&cache=shared")
+	if err != nil {
+		return nil, fmt.Errorf("opening database %s: %v", fpath, err)
+	}
+
+	if _, err := sqldb.Exec(`
+		create table if not exists email_queue (
+			id           integer primary key,
+			message      text    not null, -- json
+			state        text    not null,
+			attempts     integer not null default 0,
+			next_attempt integer not null, -- unix time
+			last_error   text    not null default '',
+			created      integer not null  -- unix time
+		);
+		create index if not exists email_queue_next_attempt on email_queue (state, next_attempt);
+	`); err != nil {
+		return nil, err
+	}
+
+	insert, err := sqldb.Prepare("insert into email_queue (message, state, next_attempt, created) values (?, '" + StatePending + "', ?, ?)")
+	if err != nil {
+		return nil, err
+	}
+	due, err := sqldb.Prepare("select id, message, attempts from email_queue where state = '" + StatePending + "' and next_attempt <= ? order by next_attempt limit 100")
+	if err != nil {
+		return nil, err
+	}
+	remove, err := sqldb.Prepare("delete from email_queue where id = ?")
+	if err != nil {
+		return nil, err
+	}
+	fail, err := sqldb.Prepare("update email_queue set message = ?, state = ?, attempts = ?, next_attempt = ?, last_error = ? where id = ?")
+	if err != nil {
+		return nil, err
+	}
+	list, err := sqldb.Prepare("select id, message, state, attempts, next_attempt, last_error, created from email_queue order by id")
+	if err != nil {
+		return nil, err
+	}
+	retry, err := sqldb.Prepare("update email_queue set state = '" + StatePending + "', attempts = 0, next_attempt = ? where id = ?")
+	if err != nil {
+		return nil, err
+	}
+	countDue, err := sqldb.Prepare("select count(*) from email_queue where state = '" + StatePending + "' and next_attempt <= ?")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Queue{
+		Emailer:  emailer,
+		now:      time.Now,
+		wake:     make(chan struct{}, 1),
+		sqldb:    sqldb,
+		insert:   insert,
+		due:      due,
+		remove:   remove,
+		fail:     fail,
+		list:     list,
+		retry:    retry,
+		countDue: countDue,
+	}, nil
+}
+
+func (q *Queue) Send(to string, subject string, body []byte) error {
+	return q.SendMessage(&Message{
+		To:      []string{to},
+		Subject: subject,
+		Text:    body,
+	})
+}
+
+// SendMessage validates msg and stores it in the queue. If msg.MessageID is empty, it is set to a new Message-ID with the domain of msg.From or q.From, so you can store it and match bounces later (see package bounce).
+// If both are empty or if q.Emailer is not a MessageSender, msg.MessageID remains empty and the Emailer creates the Message-ID on the first delivery attempt. It is kept for further attempts.
+func (q *Queue) SendMessage(msg *Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+	from := msg.From
+	if from == "" {
+		from = q.From
+	}
+	if _, ok := q.Emailer.(MessageSender); ok && msg.MessageID == "" && from != "" {
+		messageID, err := NewMessageID(from)
+		if err != nil {
+			return err
+		}
+		msg.MessageID = messageID
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	now := q.now().Unix()
+	if _, err := q.insert.Exec(data, now, now); err != nil {
+		return fmt.Errorf("queueing email: %w", err)
+	}
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// RunDaemon starts a loop which delivers due messages whenever a message is queued, and at least every minute. The function blocks.
+func (q *Queue) RunDaemon() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		if err := q.DeliverDue(); err != nil {
+			log.Printf("\033[31m"+"error delivering queued emails: %v"+"\033[0m", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-q.wake:
+		}
+	}
+}
+
+// DeliverDue delivers the pending messages whose next attempt is due. It is called by RunDaemon.
+func (q *Queue) DeliverDue() error {
+	for {
+		n, err := q.deliverBatch()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return nil
+		}
+	}
+}
+
+// deliverBatch returns the number of delivered messages. Failed messages are rescheduled, so they are not returned again by the next batch.
+func (q *Queue) deliverBatch() (int, error) {
+	type entry struct {
+		id       int64
+		data     []byte
+		msg      *Message
+		attempts int
+	}
+	var entries []entry
+	rows, err := q.due.Query(q.now().Unix())
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		var e entry
+		if err := rows.Scan(&e.id, &e.data, &e.attempts); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if err := json.Unmarshal(e.data, &e.msg); err != nil {
+			e.msg = nil // marked as dead below, so it does not block the queue
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, e := range entries {
+		if e.msg == nil {
+			if _, err := q.fail.Exec(e.data, StateDead, e.attempts, q.now().Unix(), "unmarshaling message failed", e.id); err != nil {
+				return 0, err
+			}
+			log.Printf("\033[31m"+"error unmarshaling queued email %d, marked as dead"+"\033[0m", e.id)
+			continue
+		}
+
+		sendErr := SendMessage(q.Emailer, e.msg)
+		if sendErr == nil {
+			if _, err := q.remove.Exec(e.id); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		attempts := e.attempts + 1
+		state := StatePending
+		if attempts >= q.maxAttempts() || permanent(sendErr) {
+			state = StateDead
+		}
+		next := q.now().Add(q.backoff(attempts))
+		data, err := json.Marshal(e.msg) // keeps the Message-ID if the Emailer has set it
+		if err != nil {
+			return 0, err
+		}
+		if _, err := q.fail.Exec(data, state, attempts, next.Unix(), sendErr.Error(), e.id); err != nil {
+			return 0, err
+		}
+		log.Printf("\033[31m"+"error sending queued email %d (attempt %d, %s): %v"+"\033[0m", e.id, attempts, state, sendErr)
+	}
+	return len(entries), nil
+}
+
+// permanent returns whether err is a permanent failure, so retrying is pointless.
+func permanent(err error) bool {
+	if errors.Is(err, ErrInvalidAddress) || errors.Is(err, ErrInvalidHeader) || errors.Is(err, ErrPlainEmailer) {
+		return true
+	}
+	var smtpErr *smtp.SMTPError
+	return errors.As(err, &smtpErr) && smtpErr.Code >= 500
+}
+
+func (q *Queue) maxAttempts() int {
+	if q.MaxAttempts > 0 {
+		return q.MaxAttempts
+	}
+	return 10
+}
+
+// backoff returns the delay after the given number of failed attempts.
+func (q *Queue) backoff(attempts int) time.Duration {
+	delay := q.Backoff
+	if delay <= 0 {
+		delay = time.Minute
+	}
+	for i := 1; i < attempts && delay < 24*time.Hour; i++ {
+		delay *= 2
+	}
+	return min(delay, 24*time.Hour)
+}
+
+// List returns all queued messages, including dead ones.
+func (q *Queue) List() ([]QueuedEmail, error) {
+	rows, err := q.list.Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []QueuedEmail
+	for rows.Next() {
+		var qe QueuedEmail
+		var data []byte
+		var next, created int64
+		if err := rows.Scan(&qe.ID, &data, &qe.State, &qe.Attempts, &next, &qe.LastError, &created); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &qe.Message); err != nil {
+			qe.Message = nil
+		}
+		qe.NextAttempt = time.Unix(next, 0)
+		qe.Created = time.Unix(created, 0)
+		result = append(result, qe)
+	}
+	return result, rows.Err()
+}
+
+// Retry resets the attempts of a message, so it is delivered as soon as possible, even if it is dead.
+func (q *Queue) Retry(id int64) error {
+	return q.affectOne(q.retry.Exec(q.now().Unix(), id))
+}
+
+// Drop removes a message from the queue.
+func (q *Queue) Drop(id int64) error {
+	return q.affectOne(q.remove.Exec(id))
+}
+
+func (q *Queue) affectOne(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Pending returns the number of messages which are due for delivery.
+func (q *Queue) Pending() (int, error) {
+	var n int
+	err := q.countDue.QueryRow(q.now().Unix()).Scan(&n)
+	return n, err
+}
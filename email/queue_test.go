@@ -0,0 +1,206 @@
+package email
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-smtp"
+)
+
+type failingMailer struct {
+	failures int   // number of calls which fail
+	err      error // default: "connection refused"
+	sent     []*Message
+}
+
+func (m *failingMailer) Send(to string, subject string, body []byte) error {
+	return m.SendMessage(&Message{To: []string{to}, Subject: subject, Text: body})
+}
+
+func (m *failingMailer) SendMessage(msg *Message) error {
+	if msg.MessageID == "" {
+		msg.MessageID = newMessageId("example.net") // like MakeMessage
+	}
+	if m.failures > 0 {
+		m.failures--
+		if m.err != nil {
+			return m.err
+		}
+		return errors.New("connection refused")
+	}
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func TestQueue(t *testing.T) {
+	mailer := &failingMailer{failures: 2}
+	q, err := OpenQueue(filepath.Join(t.TempDir(), "queue.sqlite3"), mailer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.MaxAttempts = 3
+	q.From = "shop@example.com"
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	q.now = func() time.Time { return now }
+
+	if err := q.Send("invalid", "Subject", nil); err != ErrInvalidAddress {
+		t.Fatalf("got %v, want %v", err, ErrInvalidAddress)
+	}
+	order := &Message{To: []string{"test@example.com"}, Subject: "Order", Text: []byte("Hello")}
+	if err := q.SendMessage(order); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(order.MessageID, "@example.com>") {
+		t.Fatalf("got Message-ID %q", order.MessageID)
+	}
+
+	// first attempt fails, retry after one minute
+	if err := q.DeliverDue(); err != nil {
+		t.Fatal(err)
+	}
+	list, err := q.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Attempts != 1 || list[0].State != StatePending || !list[0].NextAttempt.Equal(now.Add(time.Minute)) || list[0].LastError != "connection refused" {
+		t.Fatalf("after first attempt: got %+v", list)
+	}
+
+	// not due yet
+	if err := q.DeliverDue(); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := q.List(); list[0].Attempts != 1 {
+		t.Fatalf("attempted before next attempt: got %+v", list)
+	}
+
+	// second attempt fails, retry after two minutes
+	now = now.Add(time.Minute)
+	if err := q.DeliverDue(); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := q.List(); list[0].Attempts != 2 || !list[0].NextAttempt.Equal(now.Add(2*time.Minute)) {
+		t.Fatalf("after second attempt: got %+v", list)
+	}
+
+	// third attempt succeeds
+	now = now.Add(2 * time.Minute)
+	if err := q.DeliverDue(); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := q.List(); len(list) != 0 {
+		t.Fatalf("after delivery: got %+v", list)
+	}
+	if len(mailer.sent) != 1 || mailer.sent[0].Subject != "Order" || string(mailer.sent[0].Text) != "Hello" || mailer.sent[0].MessageID != order.MessageID {
+		t.Fatalf("got sent %+v", mailer.sent)
+	}
+
+	// dead letter after MaxAttempts
+	mailer.failures = 3
+	if err := q.Send("test@example.com", "Dead", nil); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := q.DeliverDue(); err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(time.Hour)
+	}
+	list, _ = q.List()
+	if len(list) != 1 || list[0].State != StateDead || list[0].Attempts != 3 {
+		t.Fatalf("dead: got %+v", list)
+	}
+
+	// retry dead letter
+	if err := q.Retry(list[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.DeliverDue(); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := q.List(); len(list) != 0 || len(mailer.sent) != 2 {
+		t.Fatalf("after retry: got %+v", list)
+	}
+
+	// permanent SMTP error
+	mailer.failures = 1
+	mailer.err = &smtp.SMTPError{Code: 550, Message: "User unknown"}
+	if err := q.Send("unknown@example.com", "Permanent", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.DeliverDue(); err != nil {
+		t.Fatal(err)
+	}
+	list, _ = q.List()
+	if len(list) != 1 || list[0].State != StateDead || list[0].Attempts != 1 {
+		t.Fatalf("permanent: got %+v", list)
+	}
+	if err := q.Drop(list[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// broken row does not block the queue
+	if _, err := q.insert.Exec("{broken", now.Unix(), now.Unix()); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Send("test@example.com", "After broken", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.DeliverDue(); err != nil {
+		t.Fatal(err)
+	}
+	list, _ = q.List()
+	if len(list) != 1 || list[0].State != StateDead || list[0].Message != nil || mailer.sent[len(mailer.sent)-1].Subject != "After broken" {
+		t.Fatalf("broken: got %+v", list)
+	}
+	if err := q.Drop(list[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// drop
+	if err := q.Send("test@example.com", "Drop", nil); err != nil {
+		t.Fatal(err)
+	}
+	list, _ = q.List()
+	if err := q.Drop(list[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Drop(list[0].ID); err == nil {
+		t.Fatal("drop twice: got no error")
+	}
+}
+
+func TestQueueWithoutFrom(t *testing.T) {
+	mailer := &failingMailer{failures: 1}
+	q, err := OpenQueue(filepath.Join(t.TempDir(), "queue.sqlite3"), mailer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	q.now = func() time.Time { return now }
+
+	// the Emailer creates the Message-ID on the first attempt, it is kept for the retry
+	if err := q.Send("test@example.com", "Order", []byte("Hello")); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.DeliverDue(); err != nil {
+		t.Fatal(err)
+	}
+	list, err := q.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Message.MessageID == "" {
+		t.Fatalf("after first attempt: got %+v", list)
+	}
+	now = now.Add(time.Minute)
+	if err := q.DeliverDue(); err != nil {
+		t.Fatal(err)
+	}
+	if len(mailer.sent) != 1 || mailer.sent[0].MessageID != list[0].Message.MessageID {
+		t.Fatalf("got sent %+v, want Message-ID %s", mailer.sent, list[0].Message.MessageID)
+	}
+}
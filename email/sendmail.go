@@ -32,25 +32,37 @@ import (
 //	ReadWritePaths=/var/spool/nullmailer
 type Sendmail struct {
 	From string
+	DKIM *DKIM // optional, see LoadDKIM
+	PGP  *PGP  // optional
 }
 
 func (mailer Sendmail) Send(to string, subject string, body []byte) error {
-	if !AddressValid(to) {
-		return ErrInvalidAddress
-	}
+	return mailer.SendMessage(&Message{
+		To:      []string{to},
+		Subject: subject,
+		Text:    body,
+	})
+}
 
-	mail, err := MakeEmail(mailer.From, to, subject, body)
+func (mailer Sendmail) SendMessage(msg *Message) error {
+	envelopes, err := makeEnvelopes(mailer.From, msg, mailer.PGP, mailer.DKIM)
 	if err != nil {
 		return err
 	}
+	for _, e := range envelopes {
+		if err := mailer.send(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
+func (mailer Sendmail) send(e envelope) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	envelopeFrom := mailer.From
-	envelopeTo := to
-
-	sendmail := exec.CommandContext(ctx, "/usr/sbin/sendmail", "-i", "-f", envelopeFrom, "--", envelopeTo) // -i don't treat a line with only a . character as the end of input
-	sendmail.Stdin = mail
+	args := append([]string{"-i", "-f", e.from, "--"}, e.to...) // -i don't treat a line with only a . character as the end of input
+	sendmail := exec.CommandContext(ctx, "/usr/sbin/sendmail", args...)
+	sendmail.Stdin = e.mail
 	return sendmail.Run()
 }
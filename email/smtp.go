@@ -1,32 +1,234 @@
 package email
 
 import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"net"
 	"os"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/emersion/go-sasl"
 	"github.com/emersion/go-smtp"
 )
 
+// TLS modes
+const (
+	TLSImplicit = "implicit" // TLS from the start, default port 465
+	TLSStartTLS = "starttls" // STARTTLS command, default port 587
+	TLSNone     = "none"     // no encryption, default port 25, only for localhost
+)
+
+// authentication mechanisms
+const (
+	AuthPlain   = "plain"
+	AuthLogin   = "login"
+	AuthCRAMMD5 = "cram-md5"
+	AuthNone    = "none"
+)
+
+var ErrSMTPUTF8 = errors.New("address requires SMTPUTF8, which is not supported by the server")
+
 // SMTP connects to a SMTP server. If the server is not reachable, Send fails.
+//
+// The zero values of TLS and Auth mean TLSImplicit and AuthPlain.
 type SMTP struct {
-	From     string `json:"from"`
-	Username string `json:"username"`
-	Password string `json:"password"`
-	Host     string `json:"host"`
+	From           string `json:"from"`
+	Username       string `json:"username"`
+	Password       string `json:"password"`
+	Host           string `json:"host"`                      // host or host:port
+	TLS            string `json:"tls,omitempty"`             // TLSImplicit, TLSStartTLS or TLSNone
+	Auth           string `json:"auth,omitempty"`            // AuthPlain, AuthLogin, AuthCRAMMD5 or AuthNone
+	CAFile         string `json:"ca_file,omitempty"`         // PEM file with certificates which are trusted in addition to the system pool
+	ServerName     string `json:"server_name,omitempty"`     // for certificate verification, default: hostname of Host
+	ConnectTimeout int    `json:"connect_timeout,omitempty"` // seconds, default: 30
+	CommandTimeout int    `json:"command_timeout,omitempty"` // seconds, default: 60
+	DKIM           *DKIM  `json:"dkim,omitempty"`            // optional
+	PGP            *PGP   `json:"-"`                         // optional
+}
+
+func (mailer SMTP) tlsMode() string {
+	if mailer.TLS == "" {
+		return TLSImplicit
+	}
+	return mailer.TLS
+}
+
+func (mailer SMTP) authMechanism() string {
+	if mailer.Auth == "" {
+		return AuthPlain
+	}
+	return mailer.Auth
 }
 
 func (mailer SMTP) auth() sasl.Client {
-	return sasl.NewPlainClient("", mailer.Username, mailer.Password)
+	switch mailer.authMechanism() {
+	case AuthLogin:
+		return sasl.NewLoginClient(mailer.Username, mailer.Password)
+	case AuthCRAMMD5:
+		return &cramMD5Client{mailer.Username, mailer.Password}
+	case AuthNone:
+		return nil
+	default:
+		return sasl.NewPlainClient("", mailer.Username, mailer.Password)
+	}
 }
 
 func (mailer SMTP) hostAddr() string {
-	if strings.Contains(mailer.Host, ":") {
+	if _, _, err := net.SplitHostPort(mailer.Host); err == nil {
 		return mailer.Host
 	}
-	return mailer.Host + ":465"
+	switch mailer.tlsMode() {
+	case TLSStartTLS:
+		return net.JoinHostPort(mailer.Host, "587")
+	case TLSNone:
+		return net.JoinHostPort(mailer.Host, "25")
+	default:
+		return net.JoinHostPort(mailer.Host, "465")
+	}
+}
+
+func (mailer SMTP) hostname() string {
+	if host, _, err := net.SplitHostPort(mailer.Host); err == nil {
+		return host
+	}
+	return mailer.Host
+}
+
+func (mailer SMTP) seconds(value, def int) time.Duration {
+	if value <= 0 {
+		value = def
+	}
+	return time.Duration(value) * time.Second
+}
+
+// validate returns an error if the configuration is incomplete or inconsistent.
+func (mailer SMTP) validate() error {
+	if !AddressValid(mailer.From) {
+		return fmt.Errorf("invalid from address: %q", mailer.From)
+	}
+	if mailer.Host == "" {
+		return errors.New("host is missing")
+	}
+	switch mailer.tlsMode() {
+	case TLSImplicit, TLSStartTLS:
+	case TLSNone:
+		if !isLocalhost(mailer.hostname()) {
+			return fmt.Errorf("tls %q is only allowed for localhost, not for %s", TLSNone, mailer.hostname())
+		}
+	default:
+		return fmt.Errorf("unknown tls mode %q, want %q, %q or %q", mailer.TLS, TLSImplicit, TLSStartTLS, TLSNone)
+	}
+	switch mailer.authMechanism() {
+	case AuthPlain, AuthLogin, AuthCRAMMD5:
+		if mailer.Username == "" {
+			return fmt.Errorf("username is missing, required for auth %q", mailer.authMechanism())
+		}
+	case AuthNone:
+	default:
+		return fmt.Errorf("unknown auth mechanism %q, want %q, %q, %q or %q", mailer.Auth, AuthPlain, AuthLogin, AuthCRAMMD5, AuthNone)
+	}
+	return nil
+}
+
+func isLocalhost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (mailer SMTP) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName: mailer.ServerName,
+	}
+	if config.ServerName == "" {
+		config.ServerName = mailer.hostname()
+	}
+	if mailer.CAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		data, err := os.ReadFile(mailer.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading ca file: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in ca file %s", mailer.CAFile)
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
+
+// dial connects to the server, sets up TLS and authenticates.
+func (mailer SMTP) dial() (*smtp.Client, error) {
+	if err := mailer.validate(); err != nil {
+		return nil, err
+	}
+	tlsConfig, err := mailer.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: mailer.seconds(mailer.ConnectTimeout, 30)}
+	var conn net.Conn
+	if mailer.tlsMode() == TLSImplicit {
+		conn, err = tls.DialWithDialer(dialer, "tcp", mailer.hostAddr(), tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", mailer.hostAddr())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("dialing %s: %w", mailer.hostAddr(), err)
+	}
+
+	// NewClient reads the greeting, but it overrides any deadline of conn with five minutes, so we close conn after CommandTimeout
+	timer := time.AfterFunc(mailer.seconds(mailer.CommandTimeout, 60), func() { conn.Close() })
+	client, err := smtp.NewClient(conn, mailer.hostname())
+	if !timer.Stop() {
+		if client != nil {
+			client.Close()
+		}
+		return nil, fmt.Errorf("connecting to %s: timeout reading greeting", mailer.hostAddr())
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("connecting to %s: %w", mailer.hostAddr(), err)
+	}
+	client.CommandTimeout = mailer.seconds(mailer.CommandTimeout, 60)
+
+	if mailer.tlsMode() == TLSStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, errors.New("server does not support STARTTLS")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("starting tls: %w", err)
+		}
+	}
+
+	if auth := mailer.auth(); auth != nil {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			client.Close()
+			return nil, errors.New("server does not support AUTH")
+		}
+		if err := client.Auth(auth); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("authenticating: %w", err)
+		}
+	}
+	return client, nil
 }
 
 func createConfig(jsonPath string) error {
@@ -46,6 +248,13 @@ func createConfig(jsonPath string) error {
 // If the file does not exist, an empty file is created:
 //
 //	{"from":"","username":"","password":"","host":""}
+//
+// For STARTTLS on port 587, add "tls":"starttls". For a local relay without authentication, use "host":"localhost","tls":"none","auth":"none".
+// Further optional fields are "auth" ("plain", "login", "cram-md5" or "none"), "ca_file", "server_name", "connect_timeout" and "command_timeout" (in seconds).
+//
+// To sign outgoing emails with DKIM, add:
+//
+//	"dkim":{"domain":"example.com","selector":"mail","key_path":"/etc/dkim/example.com.pem"}
 func LoadSMTP(jsonPath string) (*SMTP, error) {
 	data, err := os.ReadFile(jsonPath)
 	if os.IsNotExist(err) {
@@ -56,19 +265,27 @@ func LoadSMTP(jsonPath string) (*SMTP, error) {
 	}
 
 	var mailer = &SMTP{}
-	if err := json.Unmarshal(data, mailer); err != nil {
-		return nil, fmt.Errorf("unmarshaling json: %w", err)
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.DisallowUnknownFields() // report typos
+	if err := decoder.Decode(mailer); err != nil {
+		return nil, fmt.Errorf("unmarshaling %s: %w", jsonPath, err)
+	}
+	if err := mailer.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", jsonPath, err)
+	}
+	if mailer.DKIM != nil {
+		if err := mailer.DKIM.load(); err != nil {
+			return nil, err
+		}
 	}
 
-	// check authentication
-	client, err := smtp.DialTLS(mailer.hostAddr(), nil)
+	// check connection and authentication
+	client, err := mailer.dial()
 	if err != nil {
-		return nil, fmt.Errorf("dialing host: %w", err)
-	}
-	if err := client.Auth(mailer.auth()); err != nil {
-		return nil, fmt.Errorf("authenticating: %w", err)
+		return nil, err
 	}
-	if err := client.Close(); err != nil {
+	if err := client.Quit(); err != nil {
+		client.Close()
 		return nil, err
 	}
 
@@ -76,14 +293,91 @@ func LoadSMTP(jsonPath string) (*SMTP, error) {
 }
 
 func (mailer SMTP) Send(to string, subject string, body []byte) error {
-	if !AddressValid(to) {
-		return ErrInvalidAddress
+	return mailer.SendMessage(&Message{
+		To:      []string{to},
+		Subject: subject,
+		Text:    body,
+	})
+}
+
+func (mailer SMTP) SendMessage(msg *Message) error {
+	envelopes, err := makeEnvelopes(mailer.From, msg, mailer.PGP, mailer.DKIM)
+	if err != nil {
+		return err
+	}
+
+	client, err := mailer.dial()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	for _, e := range envelopes {
+		if err := send(client, e); err != nil {
+			return err
+		}
+	}
+	if err := client.Quit(); err != nil {
+		// the server has accepted the message, so it must not be sent again
+		log.Printf("\033[31m"+"error quitting smtp session: %v"+"\033[0m", err)
 	}
+	return nil
+}
 
-	mail, err := MakeEmail(mailer.From, to, subject, body)
+// send performs a mail transaction.
+func send(client *smtp.Client, e envelope) error {
+	// domains are punycode-encoded, but non-ASCII local parts require SMTPUTF8
+	var opts = &smtp.MailOptions{}
+	for _, addr := range append([]string{e.from}, e.to...) {
+		if !isASCII(addr) {
+			opts.UTF8 = true
+		}
+	}
+	if opts.UTF8 {
+		if ok, _ := client.Extension("SMTPUTF8"); !ok {
+			return ErrSMTPUTF8
+		}
+	}
+
+	if err := client.Mail(e.from, opts); err != nil {
+		return err
+	}
+	for _, to := range e.to {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
 	if err != nil {
 		return err
 	}
+	if _, err := e.mail.WriteTo(w); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// cramMD5Client implements the CRAM-MD5 mechanism (RFC 2195), which go-sasl lacks.
+type cramMD5Client struct {
+	username string
+	secret   string
+}
+
+func (c *cramMD5Client) Start() (string, []byte, error) {
+	return "CRAM-MD5", nil, nil
+}
 
-	return smtp.SendMailTLS(mailer.hostAddr(), mailer.auth(), mailer.From, []string{to}, mail)
+func (c *cramMD5Client) Next(challenge []byte) ([]byte, error) {
+	mac := hmac.New(md5.New, []byte(c.secret))
+	mac.Write(challenge)
+	return []byte(c.username + " " + hex.EncodeToString(mac.Sum(nil))), nil
 }
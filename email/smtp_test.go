@@ -0,0 +1,269 @@
+package email
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-sasl"
+	"github.com/emersion/go-smtp"
+)
+
+type testBackend struct {
+	lock     sync.Mutex
+	received []string // envelope from, recipients and data
+}
+
+func (be *testBackend) NewSession(c *smtp.Conn) (smtp.Session, error) {
+	return &testSession{backend: be}, nil
+}
+
+type testSession struct {
+	backend *testBackend
+	from    string
+	to      []string
+}
+
+func (s *testSession) Reset()        {}
+func (s *testSession) Logout() error { return nil }
+
+func (s *testSession) AuthPlain(username, password string) error {
+	return checkCredentials(username, password)
+}
+
+func (s *testSession) Mail(from string, opts *smtp.MailOptions) error {
+	s.from = from
+	return nil
+}
+
+func (s *testSession) Rcpt(to string) error {
+	s.to = append(s.to, to)
+	return nil
+}
+
+func (s *testSession) Data(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	s.backend.lock.Lock()
+	s.backend.received = append(s.backend.received, s.from+" "+strings.Join(s.to, ",")+"\n"+string(data))
+	s.backend.lock.Unlock()
+	return nil
+}
+
+func checkCredentials(username, password string) error {
+	if username != "user" || password != "secret" {
+		return errors.New("invalid credentials")
+	}
+	return nil
+}
+
+// cramMD5Server is the server side of CRAM-MD5.
+type cramMD5Server struct {
+	challenge string
+}
+
+func (s *cramMD5Server) Next(response []byte) ([]byte, bool, error) {
+	if s.challenge == "" {
+		s.challenge = "<1896.697170952@postoffice.example.net>"
+		return []byte(s.challenge), false, nil
+	}
+	username, digest, _ := strings.Cut(string(response), " ")
+	mac := hmac.New(md5.New, []byte("secret"))
+	mac.Write([]byte(s.challenge))
+	if username != "user" || digest != hex.EncodeToString(mac.Sum(nil)) {
+		return nil, true, errors.New("invalid credentials")
+	}
+	return nil, true, nil
+}
+
+// selfSigned returns a TLS certificate for 127.0.0.1 and the path of its PEM file.
+func selfSigned(t *testing.T) (tls.Certificate, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		DNSNames:              []string{"mail.example.com"},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caPath := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, caPath
+}
+
+// startServer starts an SMTP server. If implicitTLS is true, it speaks TLS from the start, else it offers STARTTLS.
+func startServer(t *testing.T, cert tls.Certificate, implicitTLS bool) (*testBackend, string) {
+	backend := &testBackend{}
+	server := smtp.NewServer(backend)
+	server.Domain = "localhost"
+	server.AllowInsecureAuth = true
+	server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	server.EnableAuth(sasl.Login, func(conn *smtp.Conn) sasl.Server {
+		return sasl.NewLoginServer(checkCredentials)
+	})
+	server.EnableAuth("CRAM-MD5", func(conn *smtp.Conn) sasl.Server {
+		return &cramMD5Server{}
+	})
+
+	var listener net.Listener
+	var err error
+	if implicitTLS {
+		listener, err = tls.Listen("tcp", "127.0.0.1:0", server.TLSConfig)
+	} else {
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+	return backend, listener.Addr().String()
+}
+
+func TestSMTP(t *testing.T) {
+	cert, caPath := selfSigned(t)
+	_, implicitAddr := startServer(t, cert, true)
+	_, plainAddr := startServer(t, cert, false)
+
+	tests := []struct {
+		mailer SMTP
+		ok     bool
+	}{
+		{SMTP{Host: implicitAddr, CAFile: caPath, Username: "user", Password: "secret"}, true},
+		{SMTP{Host: implicitAddr, CAFile: caPath, Username: "user", Password: "wrong"}, false},
+		{SMTP{Host: implicitAddr, Username: "user", Password: "secret"}, false}, // unknown CA
+		{SMTP{Host: implicitAddr, CAFile: caPath, ServerName: "mail.example.com", Auth: AuthLogin, Username: "user", Password: "secret"}, true},
+		{SMTP{Host: implicitAddr, CAFile: caPath, ServerName: "other.example.com", Username: "user", Password: "secret"}, false},
+		{SMTP{Host: plainAddr, TLS: TLSStartTLS, CAFile: caPath, Auth: AuthCRAMMD5, Username: "user", Password: "secret"}, true},
+		{SMTP{Host: plainAddr, TLS: TLSStartTLS, CAFile: caPath, Auth: AuthCRAMMD5, Username: "user", Password: "wrong"}, false},
+		{SMTP{Host: plainAddr, TLS: TLSNone, Auth: AuthNone}, true},
+		{SMTP{Host: "192.0.2.1:25", TLS: TLSNone, Auth: AuthNone}, false}, // not localhost
+		{SMTP{Host: plainAddr, TLS: "ssl", Auth: AuthNone}, false},
+		{SMTP{Host: plainAddr, TLS: TLSNone, Auth: "xoauth"}, false},
+	}
+
+	for i, test := range tests {
+		test.mailer.From = "shop@example.com"
+		test.mailer.ConnectTimeout = 5
+		test.mailer.CommandTimeout = 5
+		err := test.mailer.Send("customer@example.net", "Test", []byte("Hello"))
+		if (err == nil) != test.ok {
+			t.Fatalf("test %d: got error %v, want ok %t", i, err, test.ok)
+		}
+	}
+}
+
+func TestSMTPReceived(t *testing.T) {
+	cert, caPath := selfSigned(t)
+	backend, addr := startServer(t, cert, false)
+	mailer := SMTP{From: "Shop <shop@example.com>", Host: addr, TLS: TLSStartTLS, CAFile: caPath, Username: "user", Password: "secret"}
+	if err := mailer.SendMessage(&Message{To: []string{"A <a@example.net>"}, Bcc: []string{"b@example.net"}, Subject: "Test", Text: []byte("Hello")}); err != nil {
+		t.Fatal(err)
+	}
+	backend.lock.Lock()
+	defer backend.lock.Unlock()
+	if len(backend.received) != 1 || !strings.HasPrefix(backend.received[0], "shop@example.com a@example.net,b@example.net\n") {
+		t.Fatalf("got %q", backend.received)
+	}
+}
+
+func TestSMTPInternational(t *testing.T) {
+	cert, caPath := selfSigned(t)
+	backend, addr := startServer(t, cert, false)
+	mailer := SMTP{From: "shop@example.com", Host: addr, TLS: TLSStartTLS, CAFile: caPath, Username: "user", Password: "secret"}
+	if err := mailer.Send("Jürgen <j@müller.example>", "Test", []byte("Hello")); err != nil {
+		t.Fatal(err)
+	}
+	if err := mailer.Send("jürgen@example.net", "Test", []byte("Hello")); err != ErrSMTPUTF8 {
+		t.Fatalf("got %v, want %v", err, ErrSMTPUTF8)
+	}
+	backend.lock.Lock()
+	defer backend.lock.Unlock()
+	if len(backend.received) != 1 || !strings.HasPrefix(backend.received[0], "shop@example.com j@xn--mller-kva.example\n") {
+		t.Fatalf("got %q", backend.received)
+	}
+}
+
+func TestSMTPGreetingTimeout(t *testing.T) {
+	// server which accepts connections but never sends a greeting
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	mailer := SMTP{From: "shop@example.com", Host: listener.Addr().String(), TLS: TLSNone, Auth: AuthNone, CommandTimeout: 1}
+	start := time.Now()
+	if err := mailer.Send("customer@example.net", "Test", []byte("Hello")); err == nil {
+		t.Fatal("got no error")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("took %s", elapsed)
+	}
+}
+
+func TestLoadSMTP(t *testing.T) {
+	dir := t.TempDir()
+	for _, test := range []struct {
+		json string
+		want string // error substring
+	}{
+		{`{"from":"shop@example.com","host":"example.com","tls":"ssl","username":"user"}`, `unknown tls mode "ssl"`},
+		{`{"from":"shop@example.com","host":"example.com","auth":"oauth","username":"user"}`, `unknown auth mechanism "oauth"`},
+		{`{"from":"shop@example.com","host":"example.com"}`, "username is missing"},
+		{`{"from":"","host":"example.com","username":"user"}`, "invalid from address"},
+		{`{"from":"shop@example.com","host":"example.com","tls":"none","auth":"none"}`, "only allowed for localhost"},
+		{`{"from":"shop@example.com","hots":"example.com"}`, `unknown field "hots"`},
+	} {
+		path := filepath.Join(dir, "smtp.json")
+		if err := os.WriteFile(path, []byte(test.json), 0600); err != nil {
+			t.Fatal(err)
+		}
+		_, err := LoadSMTP(path)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Fatalf("%s: got %v, want %s", test.json, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package email
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/dys2p/eco/lang"
+)
+
+var ErrNoSubject = errors.New("template defines no subject")
+
+// TemplateData is passed to email templates. Because it embeds lang.Lang, templates can translate strings with {{.Tr "Hello"}}.
+type TemplateData struct {
+	lang.Lang
+	Data any
+}
+
+// A Template is an email template. It consists of a text/template file "name.txt" and an optional html/template file "name.html".
+// The text template must define the subject:
+//
+//	{{define "subject"}}{{.Tr "Your order %s" .Data.ID}}{{end}}
+//	{{.Tr "Thank you for your order."}}
+//
+// If the files are embedded, gotext-update-templates extracts the messages for translation.
+type Template struct {
+	Name string
+	text *texttemplate.Template
+	html *htmltemplate.Template // optional
+}
+
+// Templates maps names to templates.
+type Templates map[string]*Template
+
+// ParseTemplates parses all "*.txt" files in the root directory of fsys and the "*.html" files with the same name.
+func ParseTemplates(fsys fs.FS) (Templates, error) {
+	textPaths, err := fs.Glob(fsys, "*.txt")
+	if err != nil {
+		return nil, err
+	}
+	var templates = make(Templates)
+	for _, textPath := range textPaths {
+		name := strings.TrimSuffix(textPath, ".txt")
+		t := &Template{Name: name}
+		t.text, err = texttemplate.ParseFS(fsys, textPath)
+		if err != nil {
+			return nil, err
+		}
+		if t.text.Lookup("subject") == nil {
+			return nil, fmt.Errorf("%s: %w", textPath, ErrNoSubject)
+		}
+		htmlPath := name + ".html"
+		if _, err := fs.Stat(fsys, htmlPath); err == nil {
+			t.html, err = htmltemplate.ParseFS(fsys, htmlPath)
+			if err != nil {
+				return nil, err
+			}
+		}
+		templates[name] = t
+	}
+	return templates, nil
+}
+
+// Render executes the template and returns a message with subject, text and, if available, HTML. You have to set the recipients.
+func (t *Template) Render(l lang.Lang, data any) (*Message, error) {
+	var td = TemplateData{Lang: l, Data: data}
+
+	var subject = &bytes.Buffer{}
+	if err := t.text.ExecuteTemplate(subject, "subject", td); err != nil {
+		return nil, fmt.Errorf("executing subject of %s: %w", t.Name, err)
+	}
+	var text = &bytes.Buffer{}
+	if err := t.text.Execute(text, td); err != nil {
+		return nil, fmt.Errorf("executing text of %s: %w", t.Name, err)
+	}
+	var msg = &Message{
+		Subject: strings.Join(strings.Fields(subject.String()), " "), // no line breaks in the subject
+		Text:    bytes.TrimSpace(text.Bytes()),
+	}
+	if t.html != nil {
+		var html = &bytes.Buffer{}
+		if err := t.html.Execute(html, td); err != nil {
+			return nil, fmt.Errorf("executing html of %s: %w", t.Name, err)
+		}
+		msg.HTML = html.Bytes()
+	}
+	return msg, nil
+}
+
+// PreviewHandler returns a handler which renders the templates with sample data. It serves:
+//
+//	/                   list of templates and languages
+//	/{lang}/{name}      HTML version, or text version if there is no HTML template
+//	/{lang}/{name}.txt  text version
+//
+// Mount it with http.StripPrefix and only during development or behind authentication.
+func (templates Templates) PreviewHandler(langs lang.Languages, samples map[string]any) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" || r.URL.Path == "" {
+			var names []string
+			for name := range templates {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, "<!DOCTYPE html><html><body><ul>")
+			for _, name := range names {
+				fmt.Fprintf(w, "<li>%s:", htmltemplate.HTMLEscapeString(name))
+				for _, l := range langs {
+					href := path.Join("/", l.Prefix, name)
+					fmt.Fprintf(w, ` <a href=".%s">%s</a> (<a href=".%s.txt">text</a>)`, htmltemplate.HTMLEscapeString(href), htmltemplate.HTMLEscapeString(l.Prefix), htmltemplate.HTMLEscapeString(href))
+				}
+				fmt.Fprint(w, "</li>")
+			}
+			fmt.Fprint(w, "</ul></body></html>")
+			return
+		}
+
+		l, name, ok := langs.FromPath(r.URL.Path)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		name, textOnly := strings.CutSuffix(name, ".txt")
+		t, ok := templates[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		msg, err := t.Render(l, samples[name])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if textOnly || len(msg.HTML) == 0 {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			fmt.Fprintf(w, "Subject: %s\n\n", msg.Subject)
+			w.Write(msg.Text)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(msg.HTML)
+	})
+}
@@ -0,0 +1,81 @@
+package email
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/dys2p/eco/lang"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/message/catalog"
+)
+
+func TestTemplates(t *testing.T) {
+	fsys := fstest.MapFS{
+		"order.txt":  {Data: []byte("{{define \"subject\"}}\n{{.Tr \"Your order %s\" .Data.ID}}\n{{end}}\n{{.Tr \"Thank you for your order.\"}}\n")},
+		"order.html": {Data: []byte(`<p>{{.Tr "Thank you for your order."}}</p><p>{{.Data.Note}}</p>`)},
+		"plain.txt":  {Data: []byte(`{{define "subject"}}Plain{{end}}Hello`)},
+	}
+	templates, err := ParseTemplates(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cat := catalog.NewBuilder()
+	cat.SetString(language.German, "Your order %s", "Ihre Bestellung %s")
+	cat.SetString(language.German, "Thank you for your order.", "Vielen Dank für Ihre Bestellung.")
+	l := lang.Lang{Prefix: "de", Tag: language.German, Printer: message.NewPrinter(language.German, message.Catalog(cat))}
+
+	msg, err := templates["order"].Render(l, map[string]string{"ID": "A1", "Note": "<b>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Subject != "Ihre Bestellung A1" {
+		t.Fatalf("got subject %q", msg.Subject)
+	}
+	if string(msg.Text) != "Vielen Dank für Ihre Bestellung." {
+		t.Fatalf("got text %q", msg.Text)
+	}
+	if string(msg.HTML) != "<p>Vielen Dank für Ihre Bestellung.</p><p>&lt;b&gt;</p>" {
+		t.Fatalf("got html %q", msg.HTML)
+	}
+
+	msg, err = templates["plain"].Render(l, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Subject != "Plain" || string(msg.Text) != "Hello" || msg.HTML != nil {
+		t.Fatalf("got %+v", msg)
+	}
+
+	// missing subject
+	if _, err := ParseTemplates(fstest.MapFS{"x.txt": {Data: []byte("Hello")}}); err == nil {
+		t.Fatal("missing subject: got no error")
+	}
+
+	// preview
+	handler := templates.PreviewHandler(lang.Languages{l}, map[string]any{"order": map[string]string{"ID": "A1"}})
+	for path, want := range map[string]string{
+		"/":              `href="./de/order"`,
+		"/de/order":      "<p>Vielen Dank für Ihre Bestellung.</p>",
+		"/de/order.txt":  "Subject: Ihre Bestellung A1",
+		"/de/plain":      "Hello",
+		"/de/missing":    "404",
+		"/en/order.html": "404",
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if want == "404" {
+			if rec.Code != http.StatusNotFound {
+				t.Fatalf("%s: got %d, want 404", path, rec.Code)
+			}
+			continue
+		}
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Fatalf("%s: got %q, want %q", path, rec.Body.String(), want)
+		}
+	}
+}
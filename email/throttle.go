@@ -0,0 +1,300 @@
+package email
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// rate limit scopes
+const (
+	ScopeRecipient = "recipient"
+	ScopeDomain    = "domain"
+	ScopeGlobal    = "global"
+)
+
+// ErrRateLimited is returned by Throttle if a limit has been exceeded. Use errors.As to detect it, for example in order to show a friendly message.
+type ErrRateLimited struct {
+	Scope      string        // ScopeRecipient, ScopeDomain or ScopeGlobal
+	Key        string        // recipient address or domain, empty for ScopeGlobal
+	RetryAfter time.Duration // until enough tokens are available again
+}
+
+func (err ErrRateLimited) Error() string {
+	if err.Key == "" {
+		return fmt.Sprintf("rate limited (%s), retry after %s", err.Scope, err.RetryAfter.Round(time.Second))
+	}
+	return fmt.Sprintf("rate limited (%s %s), retry after %s", err.Scope, err.Key, err.RetryAfter.Round(time.Second))
+}
+
+// ErrTooManyRecipients is returned by Throttle if a message has more recipients than a limit allows at once, so retrying is pointless.
+var ErrTooManyRecipients = errors.New("too many recipients")
+
+// Limit is a token bucket which holds up to Burst tokens and is refilled continuously with Burst tokens per Period. The zero value means no limit.
+type Limit struct {
+	Burst  int
+	Period time.Duration
+}
+
+func (limit Limit) enabled() bool {
+	return limit.Burst > 0 && limit.Period > 0
+}
+
+// rate returns the tokens per second.
+func (limit Limit) rate() float64 {
+	return float64(limit.Burst) / limit.Period.Seconds()
+}
+
+// Bucket is the stored state of a token bucket.
+type Bucket struct {
+	Tokens  float64
+	Updated time.Time
+}
+
+// CounterStore stores token buckets.
+type CounterStore interface {
+	Get(key string) (Bucket, bool, error)
+	Set(key string, bucket Bucket) error
+	Expire(before time.Time) error // deletes buckets which have not been updated since before
+}
+
+// Throttle is an Emailer which enforces rate limits before it passes messages to another Emailer. Each envelope recipient (To, Cc and Bcc) takes one token from its recipient bucket, its domain bucket and the global bucket.
+// If a bucket has not enough tokens, no tokens are taken and ErrRateLimited is returned. If a message needs more tokens than a bucket can hold, ErrTooManyRecipients is returned.
+//
+// A Throttle must not be copied after first use. If multiple processes share an SQLite CounterStore, the limits are not enforced exactly.
+type Throttle struct {
+	Emailer   Emailer      // see SendMessage
+	Store     CounterStore // default: in-memory, see OpenCounterStore for a persistent store
+	Recipient Limit        // per recipient address
+	Domain    Limit        // per recipient domain
+	Global    Limit
+
+	now        func() time.Time // for testing
+	lock       sync.Mutex
+	lastExpire time.Time
+}
+
+func (t *Throttle) Send(to string, subject string, body []byte) error {
+	return t.SendMessage(&Message{
+		To:      []string{to},
+		Subject: subject,
+		Text:    body,
+	})
+}
+
+func (t *Throttle) SendMessage(msg *Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+	if err := t.take(msg.Recipients()); err != nil {
+		return err
+	}
+	return SendMessage(t.Emailer, msg)
+}
+
+type tokenRequest struct {
+	scope  string
+	key    string
+	limit  Limit
+	tokens float64
+}
+
+func (req *tokenRequest) storeKey() string {
+	if req.key == "" {
+		return req.scope
+	}
+	return req.scope + ":" + req.key
+}
+
+// take takes the tokens for the given recipients from all buckets, or from none.
+func (t *Throttle) take(recipients []string) error {
+	var requests []*tokenRequest
+	var byKey = make(map[string]*tokenRequest)
+	add := func(scope, key string, limit Limit) {
+		if !limit.enabled() {
+			return
+		}
+		req, ok := byKey[scope+":"+key]
+		if !ok {
+			req = &tokenRequest{scope: scope, key: key, limit: limit}
+			byKey[scope+":"+key] = req
+			requests = append(requests, req)
+		}
+		req.tokens++
+	}
+	for _, recipient := range recipients {
+		spec, err := addrSpec(recipient)
+		if err != nil {
+			return err
+		}
+		spec = strings.ToLower(spec)
+		add(ScopeRecipient, spec, t.Recipient)
+		add(ScopeDomain, spec[strings.LastIndex(spec, "@")+1:], t.Domain)
+		add(ScopeGlobal, "", t.Global)
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.Store == nil {
+		t.Store = &MemoryCounterStore{}
+	}
+	now := time.Now()
+	if t.now != nil {
+		now = t.now()
+	}
+
+	var buckets = make([]Bucket, len(requests))
+	for i, req := range requests {
+		bucket, ok, err := t.Store.Get(req.storeKey())
+		if err != nil {
+			return fmt.Errorf("getting rate limit counter: %w", err)
+		}
+		burst := float64(req.limit.Burst)
+		if ok {
+			bucket.Tokens = math.Min(burst, bucket.Tokens+now.Sub(bucket.Updated).Seconds()*req.limit.rate())
+		} else {
+			bucket.Tokens = burst
+		}
+		if req.tokens > burst {
+			return fmt.Errorf("%w: %d, %s limit is %d", ErrTooManyRecipients, int(req.tokens), req.scope, req.limit.Burst)
+		}
+		if bucket.Tokens < req.tokens {
+			return ErrRateLimited{
+				Scope:      req.scope,
+				Key:        req.key,
+				RetryAfter: time.Duration((req.tokens - bucket.Tokens) / req.limit.rate() * float64(time.Second)),
+			}
+		}
+		bucket.Tokens -= req.tokens
+		bucket.Updated = now
+		buckets[i] = bucket
+	}
+	for i, req := range requests {
+		if err := t.Store.Set(req.storeKey(), buckets[i]); err != nil {
+			return fmt.Errorf("setting rate limit counter: %w", err)
+		}
+	}
+
+	// buckets which have not been updated for the longest period are full again, so we can delete them
+	if now.Sub(t.lastExpire) > time.Hour {
+		period := max(t.Recipient.Period, t.Domain.Period, t.Global.Period)
+		if err := t.Store.Expire(now.Add(-period)); err != nil {
+			// the tokens are taken already, so don't fail
+			log.Printf("\033[31m"+"error expiring rate limit counters: %v"+"\033[0m", err)
+		}
+		t.lastExpire = now
+	}
+	return nil
+}
+
+// MemoryCounterStore is an in-memory CounterStore. The zero value is ready to use.
+type MemoryCounterStore struct {
+	lock    sync.Mutex
+	buckets map[string]Bucket
+}
+
+func (store *MemoryCounterStore) Get(key string) (Bucket, bool, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	bucket, ok := store.buckets[key]
+	return bucket, ok, nil
+}
+
+func (store *MemoryCounterStore) Set(key string, bucket Bucket) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	if store.buckets == nil {
+		store.buckets = make(map[string]Bucket)
+	}
+	store.buckets[key] = bucket
+	return nil
+}
+
+func (store *MemoryCounterStore) Expire(before time.Time) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	for key, bucket := range store.buckets {
+		if bucket.Updated.Before(before) {
+			delete(store.buckets, key)
+		}
+	}
+	return nil
+}
+
+// SQLiteCounterStore is a persistent CounterStore, so limits survive restarts.
+type SQLiteCounterStore struct {
+	sqldb  *sql.DB
+	get    *sql.Stmt
+	set    *sql.Stmt
+	expire *sql.Stmt
+}
+
+func OpenCounterStore(fpath string) (*SQLiteCounterStore, error) {
+	sqldb, err := sql.Open("sqlite3", fpath+"?_busy_timeout=10000&_journal=WAL&_sync=NORMAL&cache=shared")
+	if err != nil {
+		return nil, fmt.Errorf("opening database %s: %v", fpath, err)
+	}
+
+	if _, err := sqldb.Exec(`
+		create table if not exists email_throttle (
+			key     text    primary key,
+			tokens  real    not null,
+			updated integer not null -- unix nanoseconds
+		);
+		create index if not exists email_throttle_updated on email_throttle (updated);
+	`); err != nil {
+		return nil, err
+	}
+
+	get, err := sqldb.Prepare("select tokens, updated from email_throttle where key = ?")
+	if err != nil {
+		return nil, err
+	}
+	set, err := sqldb.Prepare("insert into email_throttle (key, tokens, updated) values (?, ?, ?) on conflict (key) do update set tokens = excluded.tokens, updated = excluded.updated")
+	if err != nil {
+		return nil, err
+	}
+	expire, err := sqldb.Prepare("delete from email_throttle where updated < ?")
+	if err != nil {
+		return nil, err
+	}
+
+	return &SQLiteCounterStore{
+		sqldb:  sqldb,
+		get:    get,
+		set:    set,
+		expire: expire,
+	}, nil
+}
+
+func (store *SQLiteCounterStore) Get(key string) (Bucket, bool, error) {
+	var bucket Bucket
+	var updated int64
+	switch err := store.get.QueryRow(key).Scan(&bucket.Tokens, &updated); err {
+	case nil:
+		bucket.Updated = time.Unix(0, updated)
+		return bucket, true, nil
+	case sql.ErrNoRows:
+		return Bucket{}, false, nil
+	default:
+		return Bucket{}, false, err
+	}
+}
+
+func (store *SQLiteCounterStore) Set(key string, bucket Bucket) error {
+	_, err := store.set.Exec(key, bucket.Tokens, bucket.Updated.UnixNano())
+	return err
+}
+
+func (store *SQLiteCounterStore) Expire(before time.Time) error {
+	_, err := store.expire.Exec(before.UnixNano())
+	return err
+}
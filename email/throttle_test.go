@@ -0,0 +1,85 @@
+package email
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestThrottle(t *testing.T) {
+	sqliteStore, err := OpenCounterStore(filepath.Join(t.TempDir(), "throttle.sqlite3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, store := range []CounterStore{&MemoryCounterStore{}, sqliteStore} {
+		now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+		mailer := &failingMailer{}
+		throttle := &Throttle{
+			Emailer:   mailer,
+			Store:     store,
+			Recipient: Limit{Burst: 2, Period: time.Hour},
+			Domain:    Limit{Burst: 3, Period: time.Hour},
+			Global:    Limit{Burst: 5, Period: time.Hour},
+			now:       func() time.Time { return now },
+		}
+
+		for i, test := range []struct {
+			to      string
+			scope   string // empty if ok
+			advance time.Duration
+		}{
+			{"a@example.com", "", 0},
+			{"A <A@EXAMPLE.COM>", "", 0}, // same recipient
+			{"a@example.com", ScopeRecipient, 0},
+			{"b@example.com", "", 0},
+			{"c@example.com", ScopeDomain, 0},
+			{"c@example.net", "", 0},
+			{"d@example.net", "", 0},
+			{"e@example.org", ScopeGlobal, 0},
+			{"a@example.com", "", 30 * time.Minute}, // one token refilled
+			{"a@example.com", ScopeRecipient, 0},
+		} {
+			now = now.Add(test.advance)
+			err := throttle.Send(test.to, "Contact form", []byte("Hello"))
+			var rateLimited ErrRateLimited
+			if test.scope == "" {
+				if err != nil {
+					t.Fatalf("test %d: got error %v", i, err)
+				}
+				continue
+			}
+			if !errors.As(err, &rateLimited) || rateLimited.Scope != test.scope {
+				t.Fatalf("test %d: got %v, want scope %s", i, err, test.scope)
+			}
+			if rateLimited.RetryAfter <= 0 || rateLimited.RetryAfter > time.Hour {
+				t.Fatalf("test %d: got retry after %s", i, rateLimited.RetryAfter)
+			}
+		}
+		if len(mailer.sent) != 6 {
+			t.Fatalf("got %d sent messages, want 6", len(mailer.sent))
+		}
+
+		// a rate limited message takes no tokens at all
+		now = now.Add(time.Hour)
+		if err := throttle.SendMessage(&Message{To: []string{"p@example.de", "q@example.de"}, Subject: "Contact form", Text: []byte("Hello")}); err != nil {
+			t.Fatal(err)
+		}
+		err := throttle.SendMessage(&Message{To: []string{"x@example.org"}, Cc: []string{"r@example.de", "s@example.de"}, Subject: "Spam", Text: []byte("Hello")})
+		var rateLimited ErrRateLimited
+		if !errors.As(err, &rateLimited) || rateLimited.Scope != ScopeDomain || rateLimited.Key != "example.de" {
+			t.Fatalf("got %v", err)
+		}
+		if err := throttle.SendMessage(&Message{To: []string{"x@example.org", "y@example.org", "z@example.org"}, Subject: "Contact form", Text: []byte("Hello")}); err != nil {
+			t.Fatal(err)
+		}
+
+		// a message which exceeds a burst can never be sent
+		now = now.Add(time.Hour)
+		err = throttle.SendMessage(&Message{To: []string{"1@example.org", "2@example.org", "3@example.org", "4@example.org"}, Subject: "Spam", Text: []byte("Hello")})
+		if !errors.Is(err, ErrTooManyRecipients) || errors.As(err, &rateLimited) {
+			t.Fatalf("got %v, want %v", err, ErrTooManyRecipients)
+		}
+	}
+}
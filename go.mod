@@ -1,30 +1,34 @@
 module github.com/voles/eco
 
-go 1.22
+go 1.22.0
 
 require (
-	github.com/abh/geoip v0.0.0-20160510155516-07cea4480daa
+	github.com/ProtonMail/go-crypto v1.1.6
 	github.com/dchest/captcha v1.0.0
 	github.com/dys2p/btcpay v0.6.0
 	github.com/dys2p/paypal v0.2.2
+	github.com/emersion/go-msgauth v0.7.0
 	github.com/emersion/go-sasl v0.0.0-20220912192320-0145f2c60ead
 	github.com/emersion/go-smtp v0.16.1-0.20230108191019-90d596c5fb00
 	github.com/mattn/go-sqlite3 v1.14.16
+	github.com/oschwald/maxminddb-golang v1.12.0
 	github.com/sethvargo/go-diceware v0.3.0
 	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
 	gitlab.com/golang-commonmark/markdown v0.0.0-20211110145824-bf3e522c626a
 	golang.org/x/exp v0.0.0-20230321023759-10a507213a29
-	golang.org/x/net v0.6.0
-	golang.org/x/text v0.8.0
-	golang.org/x/tools v0.6.0
+	golang.org/x/net v0.30.0
+	golang.org/x/text v0.21.0
+	golang.org/x/tools v0.26.0
 )
 
 require (
+	github.com/cloudflare/circl v1.3.7 // indirect
 	gitlab.com/golang-commonmark/html v0.0.0-20191124015941-a22733972181 // indirect
 	gitlab.com/golang-commonmark/linkify v0.0.0-20191026162114-a0c2df6c8f82 // indirect
 	gitlab.com/golang-commonmark/mdurl v0.0.0-20191124015652-932350d1cb84 // indirect
 	gitlab.com/golang-commonmark/puny v0.0.0-20191124015043-9f83538fa04f // indirect
-	golang.org/x/mod v0.8.0 // indirect
-	golang.org/x/sys v0.5.0 // indirect
-	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
+	golang.org/x/crypto v0.31.0 // indirect
+	golang.org/x/mod v0.21.0 // indirect
+	golang.org/x/sync v0.10.0 // indirect
+	golang.org/x/sys v0.28.0 // indirect
 )
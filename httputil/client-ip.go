@@ -0,0 +1,161 @@
+package httputil
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// headers which contain the forwarding chain, see ClientIP
+const (
+	HeaderForwarded     = "Forwarded"       // RFC 7239
+	HeaderXForwardedFor = "X-Forwarded-For" // de facto standard
+)
+
+// TrustedProxies contains the networks of reverse proxies and the header which they set. The zero value trusts no proxy.
+type TrustedProxies struct {
+	Networks []netip.Prefix
+	Header   string // HeaderForwarded or HeaderXForwardedFor, else the forwarding chain is ignored
+}
+
+// ParseTrustedProxies parses CIDR prefixes like "10.0.0.0/8" and single IP addresses like "::1". Header must be HeaderForwarded or HeaderXForwardedFor, whichever your proxies set.
+func ParseTrustedProxies(header string, cidrs ...string) (TrustedProxies, error) {
+	if header != HeaderForwarded && header != HeaderXForwardedFor {
+		return TrustedProxies{}, fmt.Errorf("unsupported forwarding header: %q", header)
+	}
+	var trusted = TrustedProxies{Header: header}
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+		if !strings.Contains(cidr, "/") {
+			addr, err := netip.ParseAddr(cidr)
+			if err != nil {
+				return TrustedProxies{}, err
+			}
+			addr = addr.Unmap()
+			trusted.Networks = append(trusted.Networks, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return TrustedProxies{}, err
+		}
+		trusted.Networks = append(trusted.Networks, prefix.Masked())
+	}
+	return trusted, nil
+}
+
+// Contains returns whether addr is in one of the trusted networks.
+func (trusted TrustedProxies) Contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range trusted.Networks {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the IP address of the client.
+//
+// If the remote address is a trusted proxy, ClientIP walks the forwarding chain from the right and skips trusted hops. The first untrusted hop is the client. If all hops are trusted, the leftmost hop is returned.
+// If a hop is not an IP address, like "unknown" or an obfuscated identifier (RFC 7239 section 6), the walk stops and the last valid address is returned.
+// The chain is taken from trusted.Header only. The other header is ignored, because proxies usually pass it through unchanged, so clients could spoof their address with it.
+// Headers sent by untrusted clients are ignored as well.
+//
+// If you use nginx as a reverse proxy, set "proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;" and use HeaderXForwardedFor and the address of nginx in trusted.
+func ClientIP(r *http.Request, trusted TrustedProxies) (netip.Addr, error) {
+	remote, err := parseAddr(r.RemoteAddr) // RemoteAddr is "IP:port"
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("parsing remote address: %w", err)
+	}
+	if !trusted.Contains(remote) {
+		return remote, nil
+	}
+
+	var hops []string
+	switch trusted.Header {
+	case HeaderForwarded:
+		hops = forwardedFor(r.Header.Values("Forwarded"))
+	case HeaderXForwardedFor:
+		for _, value := range r.Header.Values("X-Forwarded-For") {
+			for _, hop := range strings.Split(value, ",") {
+				if hop = strings.TrimSpace(hop); hop != "" {
+					hops = append(hops, hop)
+				}
+			}
+		}
+	}
+
+	var client = remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := parseAddr(hops[i])
+		if err != nil {
+			break
+		}
+		client = addr
+		if !trusted.Contains(addr) {
+			break
+		}
+	}
+	return client, nil
+}
+
+// parseAddr parses an IP address with or without port. IPv6 addresses with port must be in square brackets.
+func parseAddr(s string) (netip.Addr, error) {
+	if addrPort, err := netip.ParseAddrPort(s); err == nil {
+		return addrPort.Addr().Unmap(), nil
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	return addr.WithZone("").Unmap(), nil
+}
+
+// forwardedFor returns the "for" parameters of Forwarded header values, like `for=192.0.2.60;proto=http;by=203.0.113.43, for="[2001:db8:cafe::17]:4711"`.
+// Elements without a "for" parameter are skipped.
+func forwardedFor(values []string) []string {
+	var hops []string
+	for _, value := range values {
+		for _, element := range splitQuoted(value, ',') {
+			for _, pair := range splitQuoted(element, ';') {
+				key, val, ok := strings.Cut(pair, "=")
+				if !ok || !strings.EqualFold(strings.TrimSpace(key), "for") {
+					continue
+				}
+				val = strings.TrimSpace(val)
+				if len(val) >= 2 && val[0] == '"' && val[len(val)-1] == '"' {
+					val = strings.ReplaceAll(val[1:len(val)-1], `\`, "")
+				}
+				hops = append(hops, val)
+			}
+		}
+	}
+	return hops
+}
+
+// splitQuoted splits s at sep, except within quoted strings.
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	var quoted, escaped bool
+	var start int
+	for i := 0; i < len(s); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && s[i] == '\\':
+			escaped = true
+		case s[i] == '"':
+			quoted = !quoted
+		case !quoted && s[i] == sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
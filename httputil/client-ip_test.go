@@ -0,0 +1,83 @@
+package httputil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	if _, err := ParseTrustedProxies("Via", "127.0.0.1"); err == nil {
+		t.Fatal("unsupported header: got no error")
+	}
+
+	tests := []struct {
+		remoteAddr   string
+		header       string // proxy header
+		forwarded    string
+		forwardedFor string
+		want         string
+	}{
+		// no proxy
+		{"192.0.2.1:1234", HeaderXForwardedFor, "", "", "192.0.2.1"},
+		{"[2001:db8::1]:1234", HeaderXForwardedFor, "", "", "2001:db8::1"},
+		// untrusted client sends headers
+		{"192.0.2.1:1234", HeaderXForwardedFor, "", "198.51.100.1", "192.0.2.1"},
+		{"192.0.2.1:1234", HeaderForwarded, "for=198.51.100.1", "", "192.0.2.1"},
+		// trusted proxy
+		{"127.0.0.1:1234", HeaderXForwardedFor, "", "192.0.2.1", "192.0.2.1"},
+		{"127.0.0.1:1234", HeaderXForwardedFor, "", "192.0.2.1:8080", "192.0.2.1"},
+		{"[::1]:1234", HeaderXForwardedFor, "", "2001:db8::1", "2001:db8::1"},
+		{"127.0.0.1:1234", HeaderXForwardedFor, "", "", "127.0.0.1"},
+		// spoofed entry on the left, appended by a trusted proxy
+		{"127.0.0.1:1234", HeaderXForwardedFor, "", "198.51.100.1, 192.0.2.1", "192.0.2.1"},
+		// chain of trusted proxies
+		{"127.0.0.1:1234", HeaderXForwardedFor, "", "198.51.100.1, 192.0.2.1, 10.1.1.1", "192.0.2.1"},
+		{"127.0.0.1:1234", HeaderXForwardedFor, "", "10.2.2.2, 10.1.1.1", "10.2.2.2"},
+		// RFC 7239
+		{"127.0.0.1:1234", HeaderForwarded, "for=192.0.2.60;proto=http;by=203.0.113.43", "", "192.0.2.60"},
+		{"127.0.0.1:1234", HeaderForwarded, `for="[2001:db8:cafe::17]:4711"`, "", "2001:db8:cafe::17"},
+		{"127.0.0.1:1234", HeaderForwarded, `For="198.51.100.1", for=192.0.2.1, for=10.1.1.1`, "", "192.0.2.1"},
+		{"127.0.0.1:1234", HeaderForwarded, "proto=https, for=192.0.2.1", "", "192.0.2.1"},
+		// only the configured header is used, the other one may have been passed through from the client
+		{"127.0.0.1:1234", HeaderForwarded, "for=192.0.2.1", "198.51.100.1", "192.0.2.1"},
+		{"127.0.0.1:1234", HeaderXForwardedFor, "for=149.102.246.28", "149.102.246.28, 185.213.155.66", "185.213.155.66"},
+		{"127.0.0.1:1234", HeaderXForwardedFor, "for=149.102.246.28", "", "127.0.0.1"},
+		{"127.0.0.1:1234", HeaderForwarded, "", "198.51.100.1", "127.0.0.1"},
+		// hop which is not an IP address: stop at the last valid address
+		{"127.0.0.1:1234", HeaderForwarded, "for=unknown", "", "127.0.0.1"},
+		{"127.0.0.1:1234", HeaderForwarded, "for=192.0.2.1, for=unknown, for=10.1.1.1", "", "10.1.1.1"},
+		{"127.0.0.1:1234", HeaderForwarded, `for=192.0.2.1, for="_hidden"`, "", "127.0.0.1"},
+		{"127.0.0.1:1234", HeaderXForwardedFor, "", "unknown, 192.0.2.1", "192.0.2.1"},
+	}
+
+	for _, test := range tests {
+		trusted, err := ParseTrustedProxies(test.header, "127.0.0.1", "::1", "10.0.0.0/8")
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = test.remoteAddr
+		if test.forwarded != "" {
+			req.Header.Set("Forwarded", test.forwarded)
+		}
+		if test.forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", test.forwardedFor)
+		}
+		got, err := ClientIP(req, trusted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != test.want {
+			t.Fatalf("%+v: got %s, want %s", test, got, test.want)
+		}
+	}
+
+	// zero value trusts no proxy
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "127.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "192.0.2.1")
+	if got, err := ClientIP(req, TrustedProxies{}); err != nil || got.String() != "127.0.0.1" {
+		t.Fatalf("zero value: got %s, %v", got, err)
+	}
+}
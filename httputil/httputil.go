@@ -1,4 +1,4 @@
-// Package httputil provides an easy way to chain handlers and a server with timeouts and graceful shutdown.
+// Package httputil provides an easy way to chain handlers, a server with timeouts and graceful shutdown, and client IP extraction behind reverse proxies.
 package httputil
 
 import (
@@ -192,44 +192,57 @@ func (config Config) processNode(templateMessages *[]pipeline.Message, node pars
 	}
 	if node.Type() == parse.NodeAction {
 		if actionNode, ok := node.(*parse.ActionNode); ok {
-			for _, cmd := range actionNode.Pipe.Cmds {
-				if !containsIdentifier(cmd, config.TranslateFuncName) {
-					continue
-				}
-				for _, arg := range cmd.Args {
-					if arg.Type() == parse.NodeString {
-						if stringNode, ok := arg.(*parse.StringNode); ok {
-							text := stringNode.Text
-							placeholders := []pipeline.Placeholder{}
-
-							if strings.Contains(stringNode.String(), "%d") {
-								for i, _ := range cmd.Args[2:] {
-									id := fmt.Sprintf("arg%d", i+1)
-
-									placeholders = append(placeholders, pipeline.Placeholder{
-										ID:             id,
-										String:         fmt.Sprintf("%%[%d]d", i+1),
-										Type:           "int",
-										UnderlyingType: "int",
-										ArgNum:         i + 1,
-										Expr:           id,
-									})
-
-									text = strings.Replace(text, "%d", fmt.Sprintf("{%s}", id), 1)
-								}
-							}
-
-							message := pipeline.Message{
-								ID:  pipeline.IDList{text},
-								Key: stringNode.Text,
-								Message: pipeline.Text{
-									Msg: text,
-								},
-								Placeholders: placeholders,
-							}
-							*templateMessages = append(*templateMessages, message)
+			config.processPipe(templateMessages, actionNode.Pipe)
+		}
+	}
+}
+
+// processPipe extracts messages from the commands of a pipeline, including parenthesized pipelines like {{printf "%s: %s" (.Tr "Order") .ID}}.
+func (config Config) processPipe(templateMessages *[]pipeline.Message, pipe *parse.PipeNode) {
+	if pipe == nil {
+		return
+	}
+	for _, cmd := range pipe.Cmds {
+		for _, arg := range cmd.Args {
+			if pipeNode, ok := arg.(*parse.PipeNode); ok {
+				config.processPipe(templateMessages, pipeNode)
+			}
+		}
+		if !containsIdentifier(cmd, config.TranslateFuncName) {
+			continue
+		}
+		for _, arg := range cmd.Args {
+			if arg.Type() == parse.NodeString {
+				if stringNode, ok := arg.(*parse.StringNode); ok {
+					text := stringNode.Text
+					placeholders := []pipeline.Placeholder{}
+
+					if strings.Contains(stringNode.String(), "%d") {
+						for i, _ := range cmd.Args[2:] {
+							id := fmt.Sprintf("arg%d", i+1)
+
+							placeholders = append(placeholders, pipeline.Placeholder{
+								ID:             id,
+								String:         fmt.Sprintf("%%[%d]d", i+1),
+								Type:           "int",
+								UnderlyingType: "int",
+								ArgNum:         i + 1,
+								Expr:           id,
+							})
+
+							text = strings.Replace(text, "%d", fmt.Sprintf("{%s}", id), 1)
 						}
 					}
+
+					message := pipeline.Message{
+						ID:  pipeline.IDList{text},
+						Key: stringNode.Text,
+						Message: pipeline.Text{
+							Msg: text,
+						},
+						Placeholders: placeholders,
+					}
+					*templateMessages = append(*templateMessages, message)
 				}
 			}
 		}
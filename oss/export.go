@@ -0,0 +1,119 @@
+package oss
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/dys2p/eco/countries"
+)
+
+// memberStateCode returns the code which is used for VAT purposes. It equals the ISO 3166-1 code, except for Greece, which is "EL".
+func memberStateCode(c countries.Country) string {
+	if c == countries.GR {
+		return "EL"
+	}
+	return string(c)
+}
+
+func rateType(line Line) string {
+	if line.Standard {
+		return "STANDARD"
+	}
+	return "REDUCED"
+}
+
+// fmtRate formats basis points as percent with two decimal places, e.g. "13.50".
+func fmtRate(bp int64) string {
+	return fmt.Sprintf("%d.%02d", bp/100, bp%100)
+}
+
+// WriteCSV writes the report in the CSV format of the BZSt online portal (OSS Union scheme, version 1.0): two header lines and one record of type 1 ("supplies from Germany") per member state and VAT rate, with the fields
+//
+//	record type, member state of consumption, rate type (STANDARD or REDUCED), VAT rate, taxable amount (net), VAT amount
+//
+// Amounts are in euros with a decimal point.
+func (report *Report) WriteCSV(w io.Writer) error {
+	if _, err := io.WriteString(w, "#v1.0\n#ve1.1.0\n"); err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	for _, line := range report.Lines {
+		if err := cw.Write([]string{"1", memberStateCode(line.Country), rateType(line), fmtRate(line.BasisPoints), line.Net.String(), line.VAT.String()}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+type xmlReport struct {
+	XMLName       xml.Name  `xml:"OSSReturn"`
+	MemberState   string    `xml:"memberStateOfIdentification,attr"`
+	Period        string    `xml:"period,attr"`
+	Supplies      []xmlLine `xml:"Supply"`
+	TotalVATEuros string    `xml:"TotalVAT"`
+}
+
+type xmlLine struct {
+	MemberState   string `xml:"memberStateOfConsumption,attr"`
+	RateType      string `xml:"rateType,attr"`
+	Rate          string `xml:"rate,attr"`
+	TaxableAmount string `xml:"TaxableAmount"`
+	VATAmount     string `xml:"VATAmount"`
+}
+
+// WriteXML writes the report as XML. The element names follow the fields of the OSS return.
+func (report *Report) WriteXML(w io.Writer) error {
+	var doc = xmlReport{
+		MemberState:   memberStateCode(report.Home),
+		Period:        report.Quarter.String(),
+		TotalVATEuros: report.Total().String(),
+	}
+	for _, line := range report.Lines {
+		doc.Supplies = append(doc.Supplies, xmlLine{
+			MemberState:   memberStateCode(line.Country),
+			RateType:      rateType(line),
+			Rate:          fmtRate(line.BasisPoints),
+			TaxableAmount: line.Net.String(),
+			VATAmount:     line.VAT.String(),
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// WriteSummary writes a human-readable table of the report, with subtotals per member state.
+func (report *Report) WriteSummary(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "OSS return %s, member state of identification: %s\n\n", report.Quarter, memberStateCode(report.Home)); err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "Country\tRate type\tRate (%%)\tTaxable amount\tVAT\t\n")
+	for i, line := range report.Lines {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t\n", memberStateCode(line.Country), rateType(line), fmtRate(line.BasisPoints), line.Net, line.VAT)
+		// subtotal after the last line of each country
+		if i == len(report.Lines)-1 || report.Lines[i+1].Country != line.Country {
+			var net, vat countries.Money
+			for _, l := range report.Lines {
+				if l.Country == line.Country {
+					net += l.Net
+					vat += l.VAT
+				}
+			}
+			fmt.Fprintf(tw, "%s total\t\t\t%s\t%s\t\n", memberStateCode(line.Country), net, vat)
+		}
+	}
+	fmt.Fprintf(tw, "Total VAT\t\t\t\t%s\t\n", report.Total())
+	return tw.Flush()
+}
@@ -0,0 +1,148 @@
+// Package oss aggregates sales for the quarterly VAT return of the EU One-Stop-Shop (OSS, Union scheme) and exports it.
+//
+// Collect the B2C sales to consumers in other member states, then aggregate and export them:
+//
+//	report, err := oss.Aggregate(countries.DE, oss.QuarterOf(date), sales)
+//	if err != nil {
+//		return err
+//	}
+//	report.WriteCSV(w)
+package oss
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/dys2p/eco/countries"
+)
+
+// A Sale is a B2C supply to a consumer in a member state. Net and Gross are in cents.
+type Sale struct {
+	Country countries.Country
+	Rate    countries.Rate
+	Net     countries.Money
+	Gross   countries.Money
+	Date    time.Time
+}
+
+type Quarter struct {
+	Year    int
+	Quarter int // 1 to 4
+}
+
+func QuarterOf(t time.Time) Quarter {
+	return Quarter{
+		Year:    t.Year(),
+		Quarter: (int(t.Month())-1)/3 + 1,
+	}
+}
+
+// ParseQuarter parses strings like "2024-Q1".
+func ParseQuarter(s string) (Quarter, error) {
+	var q Quarter
+	if _, err := fmt.Sscanf(s, "%d-Q%d", &q.Year, &q.Quarter); err != nil {
+		return q, fmt.Errorf("parsing quarter %s: %w", s, err)
+	}
+	if q.Quarter < 1 || q.Quarter > 4 {
+		return q, fmt.Errorf("parsing quarter %s: invalid quarter", s)
+	}
+	return q, nil
+}
+
+func (q Quarter) String() string {
+	return fmt.Sprintf("%d-Q%d", q.Year, q.Quarter)
+}
+
+// Contains returns whether the date of t lies within the quarter.
+func (q Quarter) Contains(t time.Time) bool {
+	return QuarterOf(t) == q
+}
+
+// A Line is the tax base and VAT of all sales to a member state at a VAT rate.
+type Line struct {
+	Country     countries.Country
+	BasisPoints int64 // VAT rate in hundredths of a percent
+	Standard    bool  // whether the rate is the standard rate of the country
+	Net         countries.Money
+	VAT         countries.Money
+}
+
+type Report struct {
+	Home    countries.Country // member state of identification
+	Quarter Quarter
+	Lines   []Line // ordered by country and descending rate
+}
+
+// Aggregate sums up the sales of the quarter per member state and VAT rate. The VAT is calculated from the sum of net amounts, using the rate which applied at the date of each sale.
+//
+// Sales outside of the quarter and sales to the home country (which belong to the domestic VAT return) are skipped.
+// Aggregate returns an error if a sale is not to an EU member state, if its rate key is unknown, or if its gross amount deviates from net plus VAT by more than one cent.
+func Aggregate(home countries.Country, q Quarter, sales []Sale) (*Report, error) {
+	type key struct {
+		country  countries.Country
+		bp       int64
+		standard bool
+	}
+	var sums = make(map[key]countries.Money)
+	var errs []error
+
+	for i, sale := range sales {
+		if !q.Contains(sale.Date) || sale.Country == home {
+			continue
+		}
+		if !countries.InEuropeanUnion(sale.Country) {
+			errs = append(errs, fmt.Errorf("sale %d: %s is not an EU member state", i, sale.Country))
+			continue
+		}
+		rates := sale.Country.VATAt(sale.Date)
+		bp, ok := rates.BasisPoints(sale.Rate)
+		if !ok {
+			errs = append(errs, fmt.Errorf("sale %d: %s has no %s rate", i, sale.Country, sale.Rate))
+			continue
+		}
+		if gross, _ := rates.GrossMoney(sale.Net, sale.Rate, countries.RoundHalfUp); gross-sale.Gross > 1 || sale.Gross-gross > 1 {
+			errs = append(errs, fmt.Errorf("sale %d: gross %s does not match net %s at %s rate of %s", i, sale.Gross, sale.Net, sale.Rate, sale.Country))
+			continue
+		}
+		standardBP, _ := rates.BasisPoints(countries.RateStandard)
+		sums[key{sale.Country, bp, bp == standardBP}] += sale.Net
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	var report = &Report{
+		Home:    home,
+		Quarter: q,
+	}
+	for k, net := range sums {
+		report.Lines = append(report.Lines, Line{
+			Country:     k.country,
+			BasisPoints: k.bp,
+			Standard:    k.standard,
+			Net:         net,
+			VAT:         countries.VATFromNet(net, k.bp, countries.RoundHalfUp),
+		})
+	}
+	sort.Slice(report.Lines, func(i, j int) bool {
+		if report.Lines[i].Country != report.Lines[j].Country {
+			return report.Lines[i].Country < report.Lines[j].Country
+		}
+		if report.Lines[i].BasisPoints != report.Lines[j].BasisPoints {
+			return report.Lines[i].BasisPoints > report.Lines[j].BasisPoints
+		}
+		return report.Lines[i].Standard
+	})
+	return report, nil
+}
+
+// Total returns the total VAT which is payable for the quarter.
+func (report *Report) Total() countries.Money {
+	var total countries.Money
+	for _, line := range report.Lines {
+		total += line.VAT
+	}
+	return total
+}
@@ -0,0 +1,65 @@
+package oss
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/dys2p/eco/countries"
+)
+
+func date(s string) time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+func TestAggregate(t *testing.T) {
+	sales := []Sale{
+		{countries.AT, countries.RateStandard, 1000, 1200, date("2020-07-15")},
+		{countries.AT, countries.RateStandard, 2000, 2400, date("2020-09-30")},
+		{countries.AT, countries.RateReduced1, 1000, 1100, date("2020-08-01")},
+		{countries.AT, countries.RateParking, 1000, 1130, date("2020-08-01")}, // same rate as reduced-2
+		{countries.AT, countries.RateReduced2, 1000, 1130, date("2020-08-01")},
+		{countries.GR, countries.RateStandard, 999, 1239, date("2020-08-01")},
+		{countries.DE, countries.RateStandard, 1000, 1160, date("2020-08-01")}, // home country
+		{countries.FR, countries.RateStandard, 1000, 1200, date("2020-10-01")}, // next quarter
+	}
+
+	report, err := Aggregate(countries.DE, Quarter{2020, 3}, sales)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Line{
+		{countries.AT, 2000, true, 3000, 600},
+		{countries.AT, 1300, false, 2000, 260},
+		{countries.AT, 1000, false, 1000, 100},
+		{countries.GR, 2400, true, 999, 240},
+	}
+	if len(report.Lines) != len(want) {
+		t.Fatalf("got %v, want %v", report.Lines, want)
+	}
+	for i := range want {
+		if report.Lines[i] != want[i] {
+			t.Fatalf("got %v, want %v", report.Lines[i], want[i])
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	wantCSV := "#v1.0\n#ve1.1.0\n1,AT,STANDARD,20.00,30.00,6.00\n1,AT,REDUCED,13.00,20.00,2.60\n1,AT,REDUCED,10.00,10.00,1.00\n1,EL,STANDARD,24.00,9.99,2.40\n"
+	if got := buf.String(); got != wantCSV {
+		t.Fatalf("got %q, want %q", got, wantCSV)
+	}
+}
+
+func TestAggregateErrors(t *testing.T) {
+	sales := []Sale{
+		{countries.CH, countries.RateStandard, 1000, 1081, date("2024-01-15")},
+		{countries.AT, countries.RateStandard, 1000, 1100, date("2024-01-15")},
+	}
+	if _, err := Aggregate(countries.DE, Quarter{2024, 1}, sales); err == nil {
+		t.Fatal("got nil error")
+	}
+}
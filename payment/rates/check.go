@@ -0,0 +1,53 @@
+package rates
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Check contains sanity checks which are applied to new rates before they are inserted into the database.
+// Rates must always be positive and finite. The other checks are disabled if their field has the zero value.
+type Check struct {
+	MaxChange float64  // maximum relative change of a rate compared to the previous rates, e.g. 0.1 for ten percent
+	Required  []string // currencies which must be present
+}
+
+// Validate checks next against the sanity rules. The previous rates may be nil, for example if the database is empty.
+// All violations are joined into the returned error.
+func (check Check) Validate(prev, next map[string]float64) error {
+	var errs []error
+
+	for _, currency := range check.Required {
+		if _, ok := next[currency]; !ok {
+			errs = append(errs, fmt.Errorf("%s: missing", currency))
+		}
+	}
+
+	currencies := make([]string, 0, len(next))
+	for currency := range next {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies) // deterministic error message
+
+	for _, currency := range currencies {
+		rate := next[currency]
+		if math.IsNaN(rate) || math.IsInf(rate, 0) || rate <= 0 {
+			errs = append(errs, fmt.Errorf("%s: invalid rate %v", currency, rate))
+			continue
+		}
+		if check.MaxChange <= 0 {
+			continue
+		}
+		prevRate, ok := prev[currency]
+		if !ok || prevRate <= 0 {
+			continue // new currency, nothing to compare with
+		}
+		if change := math.Abs(rate-prevRate) / prevRate; change > check.MaxChange {
+			errs = append(errs, fmt.Errorf("%s: rate changed by %.1f%% from %v to %v", currency, change*100.0, prevRate, rate))
+		}
+	}
+
+	return errors.Join(errs...)
+}
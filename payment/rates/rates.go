@@ -3,7 +3,9 @@ package rates
 
 import (
 	"cmp"
+	"database/sql"
 	"errors"
+	"fmt"
 	"log"
 	"slices"
 	"time"
@@ -14,9 +16,13 @@ import (
 type History struct {
 	Database    *SQLiteDB
 	GetBuyRates func(lastUpdateDate string) (map[string]float64, error)
+	Check       Check                             // optional
+	Notify      func(title, message string) error // optional, called when rates are quarantined, for example a closure around ntfysh.Publish
 }
 
 // RunDaemon starts a loop which fetches the rates every hour and inserts them into the database. The function blocks.
+//
+// Rates which fail h.Check are not inserted. They are stored in the quarantine table instead, and h.Notify is called once per date and rejected rates.
 func (h *History) RunDaemon() error {
 	for ; true; <-time.Tick(time.Hour) {
 		lastUpdateDate, err := h.Database.LatestDate()
@@ -35,13 +41,40 @@ func (h *History) RunDaemon() error {
 		if len(buyRates) == 0 {
 			continue // nothing to insert
 		}
-		if err := h.Database.Insert(time.Now().Format("2006-01-02"), buyRates); err != nil {
+		if err := h.insert(time.Now().Format("2006-01-02"), lastUpdateDate, buyRates); err != nil {
 			log.Printf("\033[31m"+"error inserting rates: %v"+"\033[0m", err)
 		}
 	}
 	return nil
 }
 
+// insert validates the rates against the previous rates and either inserts or quarantines them.
+func (h *History) insert(date, lastUpdateDate string, buyRates map[string]float64) error {
+	prevRates, err := h.Database.Get(lastUpdateDate)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("getting previous rates: %w", err)
+	}
+
+	checkErr := h.Check.Validate(prevRates, buyRates)
+	if checkErr == nil {
+		return h.Database.Insert(date, buyRates)
+	}
+
+	isNew, err := h.Database.Quarantine(date, buyRates, checkErr.Error())
+	if err != nil {
+		return fmt.Errorf("quarantining rates: %w", err)
+	}
+	if isNew {
+		log.Printf("\033[31m"+"quarantined rates of %s: %v"+"\033[0m", date, checkErr)
+		if h.Notify != nil {
+			if err := h.Notify("Exchange rates quarantined", fmt.Sprintf("Rates of %s have been rejected:\n%v", date, checkErr)); err != nil {
+				log.Printf("\033[31m"+"error sending notification: %v"+"\033[0m", err)
+			}
+		}
+	}
+	return nil
+}
+
 // Get tries the given date and four previous days.
 func (h *History) Options(date string, value float64) ([]Option, error) {
 	t, err := time.Parse("2006-01-02", date)
@@ -40,3 +40,100 @@ func TestRates(t *testing.T) {
 		}
 	}
 }
+
+func TestCheck(t *testing.T) {
+	check := Check{
+		MaxChange: 0.1,
+		Required:  []string{"GBP", "USD"},
+	}
+	prev := map[string]float64{"USD": 1.1, "GBP": 0.85}
+
+	tests := []struct {
+		next  map[string]float64
+		valid bool
+	}{
+		{map[string]float64{"USD": 1.1, "GBP": 0.85}, true},
+		{map[string]float64{"USD": 1.15, "GBP": 0.8, "CHF": 0.95}, true}, // new currency is ok
+		{map[string]float64{"USD": 110, "GBP": 0.85}, false},             // off by 100x
+		{map[string]float64{"USD": 1.1}, false},                          // missing currency
+		{map[string]float64{"USD": 1.1, "GBP": 0}, false},
+		{map[string]float64{"USD": 1.1, "GBP": -0.85}, false},
+		{map[string]float64{"USD": 1.1, "GBP": math.NaN()}, false},
+		{map[string]float64{"USD": 1.1, "GBP": math.Inf(1)}, false},
+	}
+
+	for _, test := range tests {
+		if err := check.Validate(prev, test.next); (err == nil) != test.valid {
+			t.Fatalf("%v: got error %v, want valid %t", test.next, err, test.valid)
+		}
+	}
+
+	// no previous rates
+	if err := check.Validate(nil, map[string]float64{"USD": 110, "GBP": 0.85}); err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+}
+
+func TestQuarantine(t *testing.T) {
+	db, err := OpenDB(t.TempDir() + "/rates.sqlite3")
+	if err != nil {
+		t.Fatalf("opening db: %v", err)
+	}
+	if err := db.Insert("2024-01-01", map[string]float64{"USD": 1.1}); err != nil {
+		t.Fatalf("inserting: %v", err)
+	}
+
+	var notifications int
+	history := History{
+		Database: db,
+		Check:    Check{MaxChange: 0.1},
+		Notify: func(title, message string) error {
+			notifications++
+			return nil
+		},
+	}
+
+	// rejected twice, but notify only once
+	for i := 0; i < 2; i++ {
+		if err := history.insert("2024-01-02", "2024-01-01", map[string]float64{"USD": 110}); err != nil {
+			t.Fatalf("inserting: %v", err)
+		}
+	}
+	if notifications != 1 {
+		t.Fatalf("got %d notifications, want 1", notifications)
+	}
+	if _, err := db.Get("2024-01-02"); err == nil {
+		t.Fatal("quarantined rates have been inserted")
+	}
+	quarantined, err := db.Quarantined()
+	if err != nil {
+		t.Fatalf("getting quarantined rates: %v", err)
+	}
+	if len(quarantined) != 1 || quarantined[0].Date != "2024-01-02" || quarantined[0].Rates["USD"] != 110 {
+		t.Fatalf("got %v", quarantined)
+	}
+
+	// non-finite rates are quarantined too
+	notifications = 0
+	if err := history.insert("2024-01-03", "2024-01-01", map[string]float64{"USD": math.NaN(), "GBP": math.Inf(1), "CHF": math.Inf(-1)}); err != nil {
+		t.Fatalf("inserting: %v", err)
+	}
+	if notifications != 1 {
+		t.Fatalf("non-finite: got %d notifications, want 1", notifications)
+	}
+	quarantined, err = db.Quarantined()
+	if err != nil {
+		t.Fatalf("getting quarantined rates: %v", err)
+	}
+	if len(quarantined) != 2 || !math.IsNaN(quarantined[1].Rates["USD"]) || !math.IsInf(quarantined[1].Rates["GBP"], 1) || !math.IsInf(quarantined[1].Rates["CHF"], -1) {
+		t.Fatalf("non-finite: got %v", quarantined)
+	}
+
+	// valid rates are inserted
+	if err := history.insert("2024-01-02", "2024-01-01", map[string]float64{"USD": 1.12}); err != nil {
+		t.Fatalf("inserting: %v", err)
+	}
+	if _, err := db.Get("2024-01-02"); err != nil {
+		t.Fatalf("getting inserted rates: %v", err)
+	}
+}
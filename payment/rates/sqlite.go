@@ -4,15 +4,19 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"math"
+	"strconv"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
 type SQLiteDB struct {
-	sqldb  *sql.DB
-	get    *sql.Stmt
-	insert *sql.Stmt
-	latest *sql.Stmt
+	sqldb       *sql.DB
+	get         *sql.Stmt
+	insert      *sql.Stmt
+	latest      *sql.Stmt
+	quarantine  *sql.Stmt
+	quarantined *sql.Stmt
 }
 
 func OpenDB(fpath string) (*SQLiteDB, error) {
@@ -27,6 +31,12 @@ func OpenDB(fpath string) (*SQLiteDB, error) {
 			rates text not null -- json map
 		);
 		create index if not exists date_index on rates_history (date);
+		create table if not exists rates_quarantine (
+			date   text not null,
+			rates  text not null, -- json map
+			reason text not null,
+			primary key (date, rates)
+		);
 	`); err != nil {
 		return nil, err
 	}
@@ -44,11 +54,22 @@ func OpenDB(fpath string) (*SQLiteDB, error) {
 		return nil, err
 	}
 
+	quarantine, err := sqldb.Prepare("insert or ignore into rates_quarantine (date, rates, reason) values (?, ?, ?)") // ignore repeated rejections of the same rates
+	if err != nil {
+		return nil, err
+	}
+	quarantined, err := sqldb.Prepare("select date, rates, reason from rates_quarantine order by date")
+	if err != nil {
+		return nil, err
+	}
+
 	return &SQLiteDB{
-		sqldb:  sqldb,
-		get:    get,
-		insert: insert,
-		latest: latest,
+		sqldb:       sqldb,
+		get:         get,
+		insert:      insert,
+		latest:      latest,
+		quarantine:  quarantine,
+		quarantined: quarantined,
 	}, nil
 }
 
@@ -58,15 +79,11 @@ func (db *SQLiteDB) Get(date string) (map[string]float64, error) {
 	if err := db.get.QueryRow(date).Scan(&encoded); err != nil {
 		return nil, err
 	}
-	var rs = make(map[string]float64)
-	if err := json.Unmarshal(encoded, &rs); err != nil {
-		return nil, err
-	}
-	return rs, nil
+	return decodeRates(encoded)
 }
 
 func (db *SQLiteDB) Insert(date string, m map[string]float64) error {
-	encoded, err := json.Marshal(m)
+	encoded, err := encodeRates(m)
 	if err != nil {
 		return err
 	}
@@ -78,3 +95,84 @@ func (db *SQLiteDB) LatestDate() (string, error) {
 	var latest string
 	return latest, db.latest.QueryRow().Scan(&latest)
 }
+
+// Quarantine stores rejected rates. The boolean return value indicates whether the rates are new, i.e. they have not been quarantined for the same date before.
+func (db *SQLiteDB) Quarantine(date string, m map[string]float64, reason string) (bool, error) {
+	encoded, err := encodeRates(m)
+	if err != nil {
+		return false, err
+	}
+	result, err := db.quarantine.Exec(date, encoded, reason)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+type QuarantinedRates struct {
+	Date   string
+	Rates  map[string]float64
+	Reason string
+}
+
+// Quarantined returns all rejected rates, ordered by date.
+func (db *SQLiteDB) Quarantined() ([]QuarantinedRates, error) {
+	rows, err := db.quarantined.Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []QuarantinedRates
+	for rows.Next() {
+		var q QuarantinedRates
+		var encoded []byte
+		if err := rows.Scan(&q.Date, &encoded, &q.Reason); err != nil {
+			return nil, err
+		}
+		q.Rates, err = decodeRates(encoded)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, q)
+	}
+	return result, rows.Err()
+}
+
+// encodeRates encodes m as a JSON object. Because JSON has no NaN and infinity, such values are encoded as strings like "NaN" or "+Inf", so garbage rates can be quarantined.
+func encodeRates(m map[string]float64) ([]byte, error) {
+	var values = make(map[string]any, len(m))
+	for currency, rate := range m {
+		if math.IsNaN(rate) || math.IsInf(rate, 0) {
+			values[currency] = strconv.FormatFloat(rate, 'g', -1, 64)
+		} else {
+			values[currency] = rate
+		}
+	}
+	return json.Marshal(values)
+}
+
+// decodeRates reverses encodeRates.
+func decodeRates(encoded []byte) (map[string]float64, error) {
+	var values map[string]any
+	if err := json.Unmarshal(encoded, &values); err != nil {
+		return nil, err
+	}
+	var m = make(map[string]float64, len(values))
+	for currency, value := range values {
+		switch value := value.(type) {
+		case float64:
+			m[currency] = value
+		case string:
+			rate, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("decoding rate of %s: %w", currency, err)
+			}
+			m[currency] = rate
+		default:
+			return nil, fmt.Errorf("decoding rate of %s: unexpected type %T", currency, value)
+		}
+	}
+	return m, nil
+}